@@ -0,0 +1,142 @@
+package gort
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// AccessLogFormat is the format of the lines written by the AccessLog middleware.
+type AccessLogFormat int
+
+const (
+	// CommonLogFormat is the NCSA Common Log Format:
+	//	host ident authuser [date] "method path proto" status bytes
+	CommonLogFormat AccessLogFormat = iota
+	// CombinedLogFormat is the Common Log Format followed by the quoted referer and user agent.
+	CombinedLogFormat
+	// JSONLogFormat writes each request as a JSON object.
+	JSONLogFormat
+)
+
+// AccessLogConfig configures the AccessLog middleware.
+type AccessLogConfig struct {
+	// Format is the format of the access log lines.
+	Format AccessLogFormat
+
+	// Logger is the Logger the lines are written to. It defaults to the Logger of the Context.
+	// Set its encoder to RawEncoder to write the lines without timestamp and level.
+	Logger *Logger
+}
+
+// AccessLogEntry holds the information recorded for a request by the AccessLog middleware.
+// The ClientIP is given by Context.RealIP, which only trusts the forwarding headers
+// set by the Router.TrustedProxies.
+type AccessLogEntry struct {
+	Time      time.Time     `json:"time"`
+	ClientIP  string        `json:"client_ip"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Pattern   string        `json:"pattern"`
+	Proto     string        `json:"proto"`
+	Status    int           `json:"status"`
+	Bytes     int           `json:"bytes"`
+	Latency   time.Duration `json:"latency_ns"`
+	User      string        `json:"user,omitempty"`
+	Referer   string        `json:"referer,omitempty"`
+	UserAgent string        `json:"user_agent,omitempty"`
+}
+
+// AccessLog returns a middleware logging every request in the given format.
+func AccessLog(format AccessLogFormat) MiddlewareFunc {
+	return AccessLogWithConfig(AccessLogConfig{Format: format})
+}
+
+// AccessLogWithConfig returns a middleware logging every request with the given configuration.
+// Errors returned by the next handler are passed to Context.Error before the request is logged,
+// so the entry holds the status of the error response.
+func AccessLogWithConfig(config AccessLogConfig) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			start := time.Now()
+
+			if err := next(ctx); err != nil {
+				ctx.Error(err)
+			}
+
+			req := ctx.Request()
+			entry := AccessLogEntry{
+				Time:      start,
+				ClientIP:  ctx.RealIP(),
+				Method:    req.Method,
+				Path:      req.URL.RequestURI(),
+				Proto:     req.Proto,
+				Status:    ctx.Writer.Status(),
+				Bytes:     ctx.Writer.Size(),
+				Latency:   time.Since(start),
+				Referer:   req.Referer(),
+				UserAgent: req.UserAgent(),
+			}
+			if route := ctx.Route(); route != nil {
+				entry.Pattern = route.Pattern
+			}
+			if user, _, ok := req.BasicAuth(); ok {
+				entry.User = user
+			}
+
+			logger := config.Logger
+			if logger == nil {
+				logger = ctx.Logger
+			}
+			if logger != nil {
+				logger.Info(entry.format(config.Format))
+			}
+
+			return nil
+		}
+	}
+}
+
+// format returns the entry formatted as a single line.
+func (e AccessLogEntry) format(format AccessLogFormat) string {
+	switch format {
+	case JSONLogFormat:
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err.Error()
+		}
+		return string(data)
+	case CombinedLogFormat:
+		return e.common() + fmt.Sprintf(" %q %q", dash(e.Referer), dash(e.UserAgent))
+	default:
+		return e.common()
+	}
+}
+
+// common returns the entry in the Common Log Format.
+func (e AccessLogEntry) common() string {
+	size := "-"
+	if e.Bytes > 0 {
+		size = strconv.Itoa(e.Bytes)
+	}
+
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s",
+		dash(e.ClientIP),
+		dash(e.User),
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method,
+		e.Path,
+		e.Proto,
+		e.Status,
+		size,
+	)
+}
+
+// dash returns s, or "-" if s is empty.
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
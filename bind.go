@@ -0,0 +1,308 @@
+package gort
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxMemory is the maximum number of bytes of a multipart form stored in memory by Bind.
+const maxMemory = 32 << 20
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+)
+
+// Bind populates the struct pointed to by dst from the request.
+//
+// The body is decoded first, according to its Content-Type:
+// JSON and XML bodies are decoded with encoding/json and encoding/xml,
+// urlencoded and multipart forms populate the fields tagged with `form:"name"`.
+// Multipart files are bound to fields of type *multipart.FileHeader or []*multipart.FileHeader.
+// Then the fields tagged with `query:"name"`, `header:"Name"` and `param:"name"`
+// are populated from the query string, the request headers and the path parameters,
+// overriding the values decoded from the body.
+//
+// Strings, booleans, integers, floats, time.Duration, time.Time, encoding.TextUnmarshaler
+// implementations, pointers and slices of those are supported. Times are parsed as RFC 3339,
+// or with the layout given in a `layout:"..."` tag. Empty values leave the field unchanged.
+//
+// Once populated, the struct is validated with Validate.
+//
+// Bind returns a 400 Bad Request *HTTPError if a value cannot be converted,
+// a 415 Unsupported Media Type *HTTPError if the body has an unsupported Content-Type,
+// or the 422 Unprocessable Entity *HTTPError returned by Validate.
+func (ctx *Context) Bind(dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("gort: Bind destination must be a non-nil pointer to a struct, got %T", dst)
+	}
+
+	if err := ctx.bindBody(dst); err != nil {
+		return err
+	}
+
+	req := ctx.request
+	query := req.URL.Query()
+
+	sources := []struct {
+		tag    string
+		lookup func(name string) []string
+	}{
+		{"query", func(name string) []string { return query[name] }},
+		{"header", func(name string) []string { return req.Header.Values(name) }},
+		{"param", func(name string) []string {
+			if value, ok := ctx.Params[name]; ok {
+				return []string{value}
+			}
+			return nil
+		}},
+	}
+
+	for _, source := range sources {
+		if err := bindValues(v.Elem(), source.tag, source.lookup); err != nil {
+			return badRequest(err)
+		}
+	}
+
+	return ctx.Validate(dst)
+}
+
+// bindBody decodes the request body into dst according to its Content-Type.
+func (ctx *Context) bindBody(dst any) error {
+	req := ctx.request
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil && req.Header.Get("Content-Type") != "" {
+		return NewHTTPError(http.StatusUnsupportedMediaType).Wrap(err)
+	}
+
+	switch {
+	case mediaType == MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json"):
+		err = json.NewDecoder(req.Body).Decode(dst)
+	case mediaType == MIMEApplicationXML || mediaType == MIMETextXML || strings.HasSuffix(mediaType, "+xml"):
+		err = xml.NewDecoder(req.Body).Decode(dst)
+	case mediaType == "application/x-www-form-urlencoded":
+		if err = req.ParseForm(); err == nil {
+			err = bindValues(reflect.ValueOf(dst).Elem(), "form", func(name string) []string { return req.PostForm[name] })
+		}
+	case mediaType == "multipart/form-data":
+		if err = req.ParseMultipartForm(maxMemory); err == nil {
+			err = bindMultipart(reflect.ValueOf(dst).Elem(), req.MultipartForm)
+		}
+	default:
+		return NewHTTPError(http.StatusUnsupportedMediaType)
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return badRequest(err)
+	}
+
+	return nil
+}
+
+// bindError reports a value of the request that cannot be converted to the type of its field.
+type bindError struct {
+	tag  string // tag is the source of the value, e.g. "query".
+	name string
+	err  error
+}
+
+func (e *bindError) Error() string {
+	return fmt.Sprintf("%s %q: %v", e.tag, e.name, e.err)
+}
+
+func (e *bindError) Unwrap() error {
+	return e.err
+}
+
+// badRequest returns the 400 Bad Request *HTTPError of a request that cannot be bound.
+// The message names the invalid value without exposing the cause, which is kept in Err.
+func badRequest(err error) *HTTPError {
+	message := "invalid request body"
+	var be *bindError
+	if errors.As(err, &be) {
+		message = fmt.Sprintf("invalid value for %s %q", be.tag, be.name)
+	}
+	return NewHTTPError(http.StatusBadRequest, message).Wrap(err)
+}
+
+// bindMultipart populates the fields of v tagged with "form" from the values and files of the form.
+func bindMultipart(v reflect.Value, form *multipart.Form) error {
+	if err := bindValues(v, "form", func(name string) []string { return form.Value[name] }); err != nil {
+		return err
+	}
+
+	return walkFields(v, "form", func(field reflect.Value, name string, _ reflect.StructField) error {
+		files := form.File[name]
+		if len(files) == 0 {
+			return nil
+		}
+
+		switch {
+		case field.Type() == fileHeaderType:
+			field.Set(reflect.ValueOf(files[0]))
+		case field.Kind() == reflect.Slice && field.Type().Elem() == fileHeaderType:
+			field.Set(reflect.ValueOf(files))
+		}
+		return nil
+	})
+}
+
+// bindValues populates the fields of v tagged with tag from the values returned by lookup.
+func bindValues(v reflect.Value, tag string, lookup func(name string) []string) error {
+	return walkFields(v, tag, func(field reflect.Value, name string, sf reflect.StructField) error {
+		values := lookup(name)
+		if len(values) == 0 {
+			return nil
+		}
+
+		if err := setField(field, values, sf.Tag.Get("layout")); err != nil {
+			return &bindError{tag: tag, name: name, err: err}
+		}
+		return nil
+	})
+}
+
+// walkFields calls fn for every settable field of the struct v tagged with tag,
+// descending into embedded structs.
+func walkFields(v reflect.Value, tag string, fn func(field reflect.Value, name string, sf reflect.StructField) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		field := v.Field(i)
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := walkFields(field, tag, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		if err := fn(field, name, sf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setField converts values to the type of field and sets it.
+// Slices receive every value, other types the first one.
+func setField(field reflect.Value, values []string, layout string) error {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 && !field.Addr().Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(field.Type(), 0, len(values))
+		for _, value := range values {
+			elem := reflect.New(field.Type().Elem()).Elem()
+			if err := setValue(elem, value, layout); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setValue(field, values[0], layout)
+}
+
+// setValue converts value to the type of field and sets it.
+func setValue(field reflect.Value, value, layout string) error {
+	if value == "" {
+		return nil
+	}
+
+	if field.Kind() == reflect.Pointer {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setValue(ptr.Elem(), value, layout); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	switch field.Type() {
+	case timeType:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+		field.SetBytes([]byte(value))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
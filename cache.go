@@ -0,0 +1,209 @@
+package gort
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachePolicy sets the Cache-Control header of the static files matching a request path
+// prefix or a file extension, e.g.:
+//
+//	router.CachePolicies = []gort.CachePolicy{
+//		{Prefix: "/assets/", CacheControl: "public, max-age=31536000, immutable"},
+//		{Extension: ".html", CacheControl: "no-cache"},
+//	}
+type CachePolicy struct {
+	Prefix       string // Prefix matches the request paths starting with it, if not empty.
+	Extension    string // Extension matches the files with this extension, e.g. ".css", if not empty.
+	CacheControl string // CacheControl is the value of the Cache-Control header.
+}
+
+// match reports whether the policy applies to the request path and the file name.
+// A policy with both a prefix and an extension requires both to match.
+func (p CachePolicy) match(urlPath, name string) bool {
+	if p.Prefix == "" && p.Extension == "" {
+		return false
+	}
+	if p.Prefix != "" && !strings.HasPrefix(urlPath, p.Prefix) {
+		return false
+	}
+	if p.Extension != "" && !strings.EqualFold(path.Ext(name), p.Extension) {
+		return false
+	}
+	return true
+}
+
+// cacheControl returns the Cache-Control header of the first policy matching
+// the request path and the file name, or an empty string if none does.
+func (r *Router) cacheControl(urlPath, name string) string {
+	for _, policy := range r.CachePolicies {
+		if policy.match(urlPath, name) {
+			return policy.CacheControl
+		}
+	}
+	return ""
+}
+
+// contentETag returns a strong ETag derived from the SHA-256 hash of the content.
+func contentETag(hash []byte) string {
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// versionETag returns a strong ETag derived from the modification time and the size of a file,
+// which identifies its version without reading it.
+func versionETag(modTime time.Time, size int64) string {
+	return `"` + strconv.FormatInt(modTime.UnixNano(), 16) + "-" + strconv.FormatInt(size, 16) + `"`
+}
+
+// etagKey identifies a version of a file: a file whose modification time
+// or size changes gets a new ETag.
+type etagKey struct {
+	name    string
+	modTime time.Time
+	size    int64
+}
+
+// etagCache caches the ETags of the files of a fs.FS without a modification time,
+// such as an embed.FS, so that they are hashed once.
+type etagCache struct {
+	mu    sync.RWMutex
+	etags map[etagKey]string
+}
+
+// etag returns the ETag of the named file of fsys.
+// Files with a modification time get an ETag derived from it and their size.
+// Other files are identified by the hash of their content, computed if it is not cached.
+func (c *etagCache) etag(fsys fs.FS, name string, info fs.FileInfo) (string, error) {
+	if !isZeroTime(info.ModTime()) {
+		return versionETag(info.ModTime(), info.Size()), nil
+	}
+
+	key := etagKey{name: name, modTime: info.ModTime(), size: info.Size()}
+
+	c.mu.RLock()
+	etag, ok := c.etags[key]
+	c.mu.RUnlock()
+	if ok {
+		return etag, nil
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	etag = contentETag(h.Sum(nil))
+
+	c.mu.Lock()
+	if c.etags == nil {
+		c.etags = make(map[etagKey]string)
+	}
+	c.etags[key] = etag
+	c.mu.Unlock()
+
+	return etag, nil
+}
+
+// hashAll hashes the files of fsys without a modification time, so that no request
+// has to read a whole file for its ETag. It does nothing if the root of fsys has a
+// modification time, as its files are then expected to have one too.
+// Files that cannot be listed or read are hashed on demand instead.
+func (c *etagCache) hashAll(fsys fs.FS) {
+	if info, err := fs.Stat(fsys, "."); err != nil || !isZeroTime(info.ModTime()) {
+		return
+	}
+
+	fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			c.etag(fsys, name, info)
+		}
+		return nil
+	})
+}
+
+// writeCacheHeaders sets the ETag, Last-Modified and Cache-Control headers of a static file
+// and reports whether the request is a conditional request the response can be skipped for.
+// In that case, it writes a 304 Not Modified response.
+// A zero modTime, e.g. for files of an embed.FS, omits the Last-Modified header.
+func writeCacheHeaders(ctx *Context, name, etag string, modTime time.Time) bool {
+	req := ctx.Request()
+	header := ctx.Writer.Header()
+
+	header.Set("ETag", etag)
+	if !isZeroTime(modTime) {
+		header.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	if ctx.router != nil {
+		if cacheControl := ctx.router.cacheControl(req.URL.Path, name); cacheControl != "" {
+			header.Set("Cache-Control", cacheControl)
+		}
+	}
+
+	if !notModified(req, etag, modTime) {
+		return false
+	}
+
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	ctx.Writer.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// notModified reports whether the conditional headers of the GET or HEAD request
+// are satisfied by the resource with the given ETag and modification time.
+// If-None-Match takes precedence over If-Modified-Since, as required by RFC 9110.
+func notModified(req *http.Request, etag string, modTime time.Time) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		return matchETag(inm, etag)
+	}
+
+	ims := req.Header.Get("If-Modified-Since")
+	if ims == "" || isZeroTime(modTime) {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// HTTP dates have a second precision.
+	return !modTime.Truncate(time.Second).After(t)
+}
+
+// matchETag reports whether the list of entity tags of an If-None-Match header
+// contains etag, using the weak comparison.
+func matchETag(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// isZeroTime reports whether t is the zero time or the Unix epoch,
+// neither of which is a meaningful modification time.
+func isZeroTime(t time.Time) bool {
+	return t.IsZero() || t.Equal(time.Unix(0, 0))
+}
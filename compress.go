@@ -0,0 +1,441 @@
+package gort
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentEncoder compresses response bodies with a content coding of the Accept-Encoding header.
+// Implementations are used concurrently by the Compress middleware.
+type ContentEncoder interface {
+	// Encoding returns the name of the content coding, e.g. "gzip".
+	Encoding() string
+
+	// NewWriter returns a writer compressing the data written to it into w.
+	// The Compress middleware closes it once the response is written,
+	// and calls its Flush() error method, if any, when the response is flushed.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// GzipEncoder is a ContentEncoder for the gzip content coding.
+// It reuses its writers, so it must not be copied after first use.
+type GzipEncoder struct {
+	// Level is the compression level, gzip.DefaultCompression if zero.
+	Level int
+
+	pool sync.Pool
+}
+
+func (e *GzipEncoder) Encoding() string {
+	return "gzip"
+}
+
+func (e *GzipEncoder) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if zw, ok := e.pool.Get().(*gzip.Writer); ok {
+		zw.Reset(w)
+		return &pooledWriter{compressor: zw, pool: &e.pool}, nil
+	}
+
+	level := e.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	zw, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledWriter{compressor: zw, pool: &e.pool}, nil
+}
+
+// DeflateEncoder is a ContentEncoder for the deflate content coding,
+// i.e. the zlib format of RFC 1950.
+// It reuses its writers, so it must not be copied after first use.
+type DeflateEncoder struct {
+	// Level is the compression level, zlib.DefaultCompression if zero.
+	Level int
+
+	pool sync.Pool
+}
+
+func (e *DeflateEncoder) Encoding() string {
+	return "deflate"
+}
+
+func (e *DeflateEncoder) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if zw, ok := e.pool.Get().(*zlib.Writer); ok {
+		zw.Reset(w)
+		return &pooledWriter{compressor: zw, pool: &e.pool}, nil
+	}
+
+	level := e.Level
+	if level == 0 {
+		level = zlib.DefaultCompression
+	}
+	zw, err := zlib.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledWriter{compressor: zw, pool: &e.pool}, nil
+}
+
+// compressor is implemented by *gzip.Writer and *zlib.Writer.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// pooledWriter returns its compressor to the pool once closed.
+type pooledWriter struct {
+	compressor
+	pool *sync.Pool
+}
+
+func (w *pooledWriter) Close() error {
+	err := w.compressor.Close()
+	w.pool.Put(w.compressor)
+	return err
+}
+
+// CompressConfig configures the Compress middleware.
+type CompressConfig struct {
+	// Encoders are the supported content codings, in order of preference.
+	// It defaults to gzip and deflate.
+	Encoders []ContentEncoder
+
+	// MinLength is the minimum length of the bodies to compress, 1024 bytes if zero.
+	MinLength int
+
+	// SkipTypes lists the media types, or media type prefixes such as "image/",
+	// of the responses that are not compressed.
+	// It defaults to the usual already compressed types, such as images, videos and archives.
+	SkipTypes []string
+}
+
+// skipTypes are the default media types not compressed by the Compress middleware.
+var skipTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+	"video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/pdf",
+	"application/octet-stream",
+}
+
+// Compress returns a middleware compressing responses with gzip or deflate.
+func Compress() MiddlewareFunc {
+	return CompressWithConfig(CompressConfig{})
+}
+
+// CompressWithConfig returns a middleware compressing responses with the given configuration.
+// The content coding is negotiated with the Accept-Encoding header of the request.
+// Bodies are buffered until MinLength bytes are written or the response is flushed,
+// so that small bodies are sent uncompressed. Responses with a Content-Encoding
+// or a Content-Range header, and responses of one of the SkipTypes, are not compressed.
+// The Content-Type is sniffed from the body if the handler did not set it,
+// and strong ETags of compressed responses are made weak, as are the ones of 304 Not Modified
+// responses to requests accepting a content coding, so that they match the revalidated response.
+// HEAD requests get the headers of the GET response, without compressing the body.
+// The Vary header of every response includes Accept-Encoding.
+func CompressWithConfig(config CompressConfig) MiddlewareFunc {
+	if len(config.Encoders) == 0 {
+		config.Encoders = []ContentEncoder{&GzipEncoder{}, &DeflateEncoder{}}
+	}
+	if config.MinLength == 0 {
+		config.MinLength = 1024
+	}
+	if config.SkipTypes == nil {
+		config.SkipTypes = skipTypes
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			ctx.Writer.Header().Add("Vary", "Accept-Encoding")
+
+			encoder := negotiateEncoding(ctx.Request().Header.Get("Accept-Encoding"), config.Encoders)
+			if encoder == nil {
+				return next(ctx)
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: ctx.Writer.ResponseWriter,
+				encoder:        encoder,
+				config:         &config,
+				head:           ctx.Request().Method == http.MethodHead,
+			}
+			ctx.Writer.ResponseWriter = cw
+			defer func() {
+				ctx.Writer.ResponseWriter = cw.ResponseWriter
+			}()
+
+			size := ctx.Writer.size
+			err := next(ctx)
+			if cerr := cw.Close(); err == nil {
+				err = cerr
+			}
+			// The body was counted before compression: record the bytes actually sent.
+			ctx.Writer.size = size + cw.sent
+			return err
+		}
+	}
+}
+
+// negotiateEncoding returns the encoder preferred by the Accept-Encoding header,
+// or nil if none is acceptable. Encoders with the same quality are preferred in order.
+func negotiateEncoding(header string, encoders []ContentEncoder) ContentEncoder {
+	if header == "" {
+		return nil
+	}
+
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+
+		q := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.ToLower(key) == "q" {
+			if v, err := strconv.ParseFloat(value, 64); err == nil && v >= 0 && v <= 1 {
+				q = v
+			}
+		}
+		qualities[coding] = q
+	}
+
+	var best ContentEncoder
+	bestQ := 0.0
+	for _, encoder := range encoders {
+		q, ok := qualities[encoder.Encoding()]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoder, q
+		}
+	}
+	return best
+}
+
+// sniffLen is the number of bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// compressWriter buffers the beginning of the body to decide whether to compress it,
+// then writes it either through the encoder or as is.
+type compressWriter struct {
+	http.ResponseWriter
+	encoder ContentEncoder
+	config  *CompressConfig
+
+	status  int
+	buf     []byte
+	decided bool
+	writer  io.WriteCloser // writer is the compressing writer, nil if the body is not compressed.
+	sent    int            // sent is the number of bytes of the body sent, after compression.
+
+	// head is set for HEAD requests: the body is dropped, and only its length and beginning
+	// are recorded so that the response carries the same headers as the GET response.
+	head    bool
+	written int
+}
+
+// WriteHeader records the status code until the decision to compress is taken.
+func (w *compressWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if w.head {
+		w.discarded(b)
+		return len(b), nil
+	}
+
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.config.MinLength {
+			return len(b), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if w.writer != nil {
+		return w.writer.Write(b)
+	}
+	return w.send(b)
+}
+
+// discarded records the bytes b of the body of a HEAD response, dropped by the writer
+// or by the Context.Writer when a GET route answers the HEAD request.
+// The first sniffLen bytes are kept to sniff the Content-Type.
+func (w *compressWriter) discarded(b []byte) {
+	if !w.decided && len(w.buf) < sniffLen {
+		w.buf = append(w.buf, b[:min(len(b), sniffLen-len(w.buf))]...)
+	}
+	w.written += len(b)
+	if !w.decided && w.written >= w.config.MinLength {
+		w.decide(true)
+	}
+}
+
+// decide sends the header and the buffered body, compressed if compress is true
+// and the response can be compressed.
+// For HEAD requests, only the header is sent, with the Content-Encoding the body would have.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if compress && w.compressible() {
+		if w.head {
+			w.setEncodingHeaders()
+		} else if writer, err := w.encoder.NewWriter(writerFunc(w.send)); err == nil {
+			w.writer = writer
+			w.setEncodingHeaders()
+		}
+	} else if w.status == http.StatusNotModified {
+		// The ETag must match the one of the compressed response being revalidated.
+		w.weakenETag()
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 || w.head {
+		return nil
+	}
+	if w.writer != nil {
+		_, err := w.writer.Write(buf)
+		return err
+	}
+	_, err := w.send(buf)
+	return err
+}
+
+// send writes b to the wrapped writer, recording the number of bytes sent.
+func (w *compressWriter) send(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.sent += n
+	return n, err
+}
+
+// writerFunc adapts a function to the io.Writer interface.
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) {
+	return f(b)
+}
+
+// setEncodingHeaders sets the headers of a compressed response.
+func (w *compressWriter) setEncodingHeaders() {
+	header := w.Header()
+	header.Set("Content-Encoding", w.encoder.Encoding())
+	header.Del("Content-Length")
+	w.weakenETag()
+}
+
+// weakenETag makes a strong ETag weak, as a compressed body is not byte-for-byte
+// identical to the representation the ETag was computed for.
+func (w *compressWriter) weakenETag() {
+	header := w.Header()
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+}
+
+// compressible reports whether the response can be compressed.
+func (w *compressWriter) compressible() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	if w.status < http.StatusOK || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+
+	contentType := strings.ToLower(header.Get("Content-Type"))
+	for _, skip := range w.config.SkipTypes {
+		if strings.HasPrefix(contentType, skip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Close sends the buffered response, uncompressed since it is shorter than MinLength,
+// and closes the compressing writer.
+// The response to a HEAD request is compressed if the Content-Length header announces
+// a body of at least MinLength bytes, like the response to the GET request.
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if w.status == 0 {
+			return nil
+		}
+		if w.head {
+			length, _ := strconv.Atoi(w.Header().Get("Content-Length"))
+			return w.decide(max(length, w.written) >= w.config.MinLength)
+		}
+		return w.decide(false)
+	}
+	if w.writer != nil {
+		return w.writer.Close()
+	}
+	return nil
+}
+
+// Flush compresses and sends the buffered body, then flushes the wrapped writer.
+func (w *compressWriter) Flush() {
+	if !w.decided && w.status != 0 {
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+	if flusher, ok := w.writer.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection.
+// It returns an error if the wrapped writer does not implement http.Hijacker.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("gort: response writer does not implement http.Hijacker")
+	}
+	return hijacker.Hijack()
+}
+
+// Push initiates an HTTP/2 server push.
+// It returns http.ErrNotSupported if the wrapped writer does not implement http.Pusher.
+func (w *compressWriter) Push(target string, opts *http.PushOptions) error {
+	pusher, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return pusher.Push(target, opts)
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for use with http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -2,29 +2,33 @@ package gort
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
 // Context holdesHTTP request context. It includes parameters,
-// the response writer, the request, the store, the logger, and a flag
-// indicating whether the response has been written.
+// the response writer, the request, the store and the logger.
+// The response writer records whether the response has been written.
 type Context struct {
-	Params    map[string]string
-	Writer    http.ResponseWriter
-	request   *http.Request
-	Store     *Store
-	Logger    *Logger
-	isWritten bool
+	Params  map[string]string
+	Writer  *ResponseWriter
+	request *http.Request
+	Store   *Store
+	Logger  *Logger
+	router  *Router
+	route   *Route
 }
 
 // CreateContext creates a new context.
 func CreateContext(w http.ResponseWriter, r *http.Request, store *Store, logger *Logger) *Context {
 	return &Context{
 		Params:  make(map[string]string),
-		Writer:  w,
+		Writer:  NewResponseWriter(w),
 		request: r,
 		Store:   store,
 		Logger:  logger,
@@ -41,6 +45,46 @@ func (ctx *Context) Param(name string) string {
 	return ctx.Params[name]
 }
 
+// ParamInt returns the value of the given parameter as an int.
+// It returns a 400 Bad Request *HTTPError if the value is not a valid int.
+func (ctx *Context) ParamInt(name string) (int, error) {
+	n, err := strconv.Atoi(ctx.Params[name])
+	if err != nil {
+		return 0, NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %s parameter", name)).Wrap(err)
+	}
+	return n, nil
+}
+
+// ParamInt64 returns the value of the given parameter as an int64.
+// It returns a 400 Bad Request *HTTPError if the value is not a valid int64.
+func (ctx *Context) ParamInt64(name string) (int64, error) {
+	n, err := strconv.ParseInt(ctx.Params[name], 10, 64)
+	if err != nil {
+		return 0, NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %s parameter", name)).Wrap(err)
+	}
+	return n, nil
+}
+
+// ParamFloat64 returns the value of the given parameter as a float64.
+// It returns a 400 Bad Request *HTTPError if the value is not a valid float64.
+func (ctx *Context) ParamFloat64(name string) (float64, error) {
+	f, err := strconv.ParseFloat(ctx.Params[name], 64)
+	if err != nil {
+		return 0, NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %s parameter", name)).Wrap(err)
+	}
+	return f, nil
+}
+
+// ParamBool returns the value of the given parameter as a bool.
+// It returns a 400 Bad Request *HTTPError if the value is not a valid bool.
+func (ctx *Context) ParamBool(name string) (bool, error) {
+	b, err := strconv.ParseBool(ctx.Params[name])
+	if err != nil {
+		return false, NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %s parameter", name)).Wrap(err)
+	}
+	return b, nil
+}
+
 // SetHeader sets a header in the response.
 func (ctx *Context) SetHeader(key, value string) {
 	ctx.Writer.Header().Set(key, value)
@@ -74,12 +118,11 @@ func (ctx *Context) BindJSON(data any) error {
 
 // SetStatus sets the HTTP status code.
 func (ctx *Context) SetStatus(code int) {
-	if ctx.isWritten {
+	if ctx.Writer.Committed() {
 		ctx.Logger.Log(WARNING, "superflous call to SetStatus")
 		return
 	}
 	ctx.Writer.WriteHeader(code)
-	ctx.isWritten = true
 }
 
 // GetHeader returns the value of the given header.
@@ -89,7 +132,7 @@ func (ctx *Context) GetHeader(key string) string {
 
 // Send writes data to the response body.
 func (ctx *Context) Send(statusCode int, data []byte) error {
-	if ctx.isWritten {
+	if ctx.Writer.Committed() {
 		ctx.Logger.Log(WARNING, "superflous call to Send")
 		return errors.New("superflous call to Send")
 	}
@@ -97,13 +140,12 @@ func (ctx *Context) Send(statusCode int, data []byte) error {
 	if err != nil {
 		return fmt.Errorf("error writing data to response: %v", err)
 	}
-	ctx.isWritten = true
 	return nil
 }
 
 // SendString writes a string to the response body.
 func (ctx *Context) WriteString(statusCode int, s string) error {
-	if ctx.isWritten {
+	if ctx.Writer.Committed() {
 		ctx.Logger.Log(WARNING, "superflous call to WriteString")
 		return errors.New("superflous call to WriteString")
 	}
@@ -111,13 +153,12 @@ func (ctx *Context) WriteString(statusCode int, s string) error {
 	if err != nil {
 		return fmt.Errorf("error writing string to response: %v", err)
 	}
-	ctx.isWritten = true
 	return nil
 }
 
 // SendJSON writes a JSON object to the response body.
 func (ctx *Context) JSON(statusCode int, a any) error {
-	if ctx.isWritten {
+	if ctx.Writer.Committed() {
 		ctx.Logger.Log(WARNING, "superflous call to JSON")
 		return errors.New("superflous call to JSON")
 	}
@@ -127,17 +168,34 @@ func (ctx *Context) JSON(statusCode int, a any) error {
 		ctx.Writer.WriteHeader(http.StatusInternalServerError)
 		return err
 	}
-	_, err = ctx.Writer.Write(jsn)
+	_, err = write(ctx, statusCode, jsn)
 	if err != nil {
 		return fmt.Errorf("error writing JSON to response: %v", err)
 	}
-	ctx.isWritten = true
+	return nil
+}
+
+// XML writes an XML object to the response body, preceded by the standard XML header.
+func (ctx *Context) XML(statusCode int, a any) error {
+	if ctx.Writer.Committed() {
+		ctx.Logger.Log(WARNING, "superflous call to XML")
+		return errors.New("superflous call to XML")
+	}
+	data, err := xml.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	ctx.Writer.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	_, err = write(ctx, statusCode, append([]byte(xml.Header), data...))
+	if err != nil {
+		return fmt.Errorf("error writing XML to response: %v", err)
+	}
 	return nil
 }
 
 // HTML writes an HTML template the response body.
 func (ctx *Context) HTML(statusCode int, html string) error {
-	if ctx.isWritten {
+	if ctx.Writer.Committed() {
 		ctx.Logger.Log(WARNING, "superflous call to HTML")
 		return errors.New("superflous call to HTML")
 	}
@@ -146,24 +204,22 @@ func (ctx *Context) HTML(statusCode int, html string) error {
 	if err != nil {
 		return fmt.Errorf("error writing HTML to response: %v", err)
 	}
-	ctx.isWritten = true
 	return nil
 }
 
 // Redirect redirects the request to a new URL.
 func (ctx *Context) Redirect(path string) error {
-	if ctx.isWritten {
+	if ctx.Writer.Committed() {
 		ctx.Logger.Log(WARNING, "superflous call to Redirect")
 		return errors.New("superflous call to Redirect")
 	}
 	http.Redirect(ctx.Writer, ctx.request, path, http.StatusFound)
-	ctx.isWritten = true
 	return nil
 }
 
 // NotFound sets the HTTP status code.
 func (ctx *Context) NotFound() error {
-	if ctx.isWritten {
+	if ctx.Writer.Committed() {
 		ctx.Logger.Log(WARNING, "superflous call to NotFound")
 		return errors.New("superflous call to NotFound")
 	}
@@ -171,13 +227,12 @@ func (ctx *Context) NotFound() error {
 	if err != nil {
 		return fmt.Errorf("error writing Not Found to response: %v", err)
 	}
-	ctx.isWritten = true
 	return nil
 }
 
 // MethodNotAllowed sets the HTTP status code 405 and writes a message to the response body.
 func (ctx *Context) MethodNotAllowed() error {
-	if ctx.isWritten {
+	if ctx.Writer.Committed() {
 		ctx.Logger.Log(WARNING, "superflous call to MethodNotAllowed")
 		return errors.New("superflous call to MethodNotAllowed")
 	}
@@ -185,13 +240,12 @@ func (ctx *Context) MethodNotAllowed() error {
 	if err != nil {
 		return fmt.Errorf("error writing Method Not Allowed to response: %v", err)
 	}
-	ctx.isWritten = true
 	return nil
 }
 
 // BadRequest sets the HTTP status code 400 and writes a message to the response body.
 func (ctx *Context) BadRequest() error {
-	if ctx.isWritten {
+	if ctx.Writer.Committed() {
 		ctx.Logger.Log(WARNING, "superflous call to BadRequest")
 		return errors.New("superflous call to BadRequest")
 	}
@@ -199,13 +253,12 @@ func (ctx *Context) BadRequest() error {
 	if err != nil {
 		return fmt.Errorf("error writing Bad Request to response: %v", err)
 	}
-	ctx.isWritten = true
 	return nil
 }
 
 // InternalServerError sets the HTTP status code 500 and writes a message to the response body.
 func (ctx *Context) InternalServerError() error {
-	if ctx.isWritten {
+	if ctx.Writer.Committed() {
 		ctx.Logger.Log(WARNING, "superflous call to InternalServerError")
 		return errors.New("superflous call to InternalServerError")
 	}
@@ -213,13 +266,12 @@ func (ctx *Context) InternalServerError() error {
 	if err != nil {
 		return fmt.Errorf("error writing Internal Server Error to response: %v", err)
 	}
-	ctx.isWritten = true
 	return nil
 }
 
 // Unauthorized sets the HTTP status code 401 and writes a message to the response body.
 func (ctx *Context) Unauthorized() error {
-	if ctx.isWritten {
+	if ctx.Writer.Committed() {
 		ctx.Logger.Log(WARNING, "superflous call to Unauthorized")
 		return errors.New("superflous call to Unauthorized")
 	}
@@ -227,13 +279,12 @@ func (ctx *Context) Unauthorized() error {
 	if err != nil {
 		return fmt.Errorf("error writing Unauthorized to response: %v", err)
 	}
-	ctx.isWritten = true
 	return nil
 }
 
 // Forbidden sets the HTTP status code 403 and writes a message to the response body.
 func (ctx *Context) Forbidden() error {
-	if ctx.isWritten {
+	if ctx.Writer.Committed() {
 		ctx.Logger.Log(WARNING, "superflous call to Forbidden")
 		return errors.New("superflous call to Forbidden")
 	}
@@ -241,16 +292,64 @@ func (ctx *Context) Forbidden() error {
 	if err != nil {
 		return fmt.Errorf("error writing Forbidden to response: %v", err)
 	}
-	ctx.isWritten = true
 	return nil
 }
 
+// Route returns the route matched by the request, or nil if no route matched
+// or if the context was not created by a Router.
+func (ctx *Context) Route() *Route {
+	return ctx.route
+}
+
+// Error passes the error to the router's ErrorHandler, which writes the error response.
+// Middlewares use it to render an error before inspecting the response.
+func (ctx *Context) Error(err error) {
+	if ctx.router == nil {
+		DefaultErrorHandler(ctx, err)
+		return
+	}
+	ctx.router.handleError(ctx, err)
+}
+
+// RealIP returns the IP address of the client.
+// The X-Forwarded-For and X-Real-IP headers can be set by any client, so they are only
+// used when the request comes from one of the Router.TrustedProxies. X-Forwarded-For is
+// then read from right to left, skipping the trusted proxies, and the first other address
+// is returned. X-Real-IP is used if there is no X-Forwarded-For header.
+// Otherwise, RealIP returns the remote address of the request.
+func (ctx *Context) RealIP() string {
+	remote, _, err := net.SplitHostPort(ctx.request.RemoteAddr)
+	if err != nil {
+		remote = ctx.request.RemoteAddr
+	}
+
+	if ctx.router == nil || !ctx.router.trusted(remote) {
+		return remote
+	}
+
+	if xff := ctx.request.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		addrs := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(addrs) - 1; i >= 0; i-- {
+			addr := strings.TrimSpace(addrs[i])
+			if i == 0 || !ctx.router.trusted(addr) {
+				return addr
+			}
+		}
+	}
+
+	if ip := ctx.request.Header.Get("X-Real-IP"); ip != "" {
+		return strings.TrimSpace(ip)
+	}
+
+	return remote
+}
+
 // Request returns the HTTP request.
 func (ctx *Context) Request() *http.Request {
 	return ctx.request
 }
 
-// ResponseWriter return teh HTTP response writer.
+// ResponseWriter returns the HTTP response writer.
 func (ctx *Context) ResponseWriter() http.ResponseWriter {
 	return ctx.Writer
 }
@@ -266,19 +365,32 @@ func (ctx *Context) FormValues() map[string][]string {
 }
 
 // extractParams extracts the parameters from the given path based on the provided pattern.
+// The path is split into segments as rnode.match does, skipping empty segments,
+// so that the parameters are the segments the route was matched with.
+// The constraints of dynamic segments (":id<int>") are not part of the parameter names.
+// A catch-all segment ("*name") captures the remainder of the path, without its leading slashes.
+// An unnamed catch-all segment ("*") is stored under the "*" key.
 func extractParams(path, pattern string) map[string]string {
-	patternParts := strings.Split(pattern, "/")
-	pathParts := strings.Split(path, "/")
+	params := make(map[string]string)
+	for _, part := range strings.Split(pattern, "/") {
+		if part == "" {
+			continue
+		}
 
-	if len(patternParts) != len(pathParts) {
-		return nil
-	}
+		if part[0] == '*' {
+			name := part[1:]
+			if name == "" {
+				name = "*"
+			}
+			params[name] = strings.TrimLeft(path, "/")
+			break
+		}
 
-	params := make(map[string]string, len(patternParts))
-	for i := 1; i < len(patternParts); i++ {
-		part := patternParts[i]
-		if len(part) > 0 && part[0] == ':' {
-			params[part[1:]] = pathParts[i]
+		var segment string
+		segment, path = nextSegment(path)
+		if part[0] == ':' {
+			name, _ := parseParam(part)
+			params[name] = segment
 		}
 	}
 
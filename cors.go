@@ -0,0 +1,157 @@
+package gort
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig configures the CORS middleware.
+type CORSConfig struct {
+	// AllowOrigins lists the origins allowed to make cross-origin requests, e.g. "https://example.com".
+	// An origin may contain a "*" wildcard, e.g. "https://*.example.com", and "*" allows every origin.
+	// It defaults to "*" if AllowOriginFunc is nil.
+	AllowOrigins []string
+
+	// AllowOriginFunc reports whether the origin is allowed, in addition to AllowOrigins.
+	AllowOriginFunc func(origin string) bool
+
+	// AllowMethods lists the methods allowed in preflight responses.
+	// It defaults to GET, HEAD, PUT, PATCH, POST and DELETE.
+	AllowMethods []string
+
+	// AllowHeaders lists the request headers allowed in preflight responses.
+	// It defaults to the headers requested by the preflight request.
+	AllowHeaders []string
+
+	// AllowCredentials allows requests with credentials, such as cookies.
+	// The origin is then echoed in the Access-Control-Allow-Origin header.
+	// It cannot be combined with the "*" origin: the allowed origins must be listed
+	// in AllowOrigins or checked by AllowOriginFunc.
+	AllowCredentials bool
+
+	// ExposeHeaders lists the response headers readable by the client.
+	ExposeHeaders []string
+
+	// MaxAge is the number of seconds the result of a preflight request can be cached.
+	// Zero omits the header, a negative value disables caching.
+	MaxAge int
+}
+
+// CORS returns a middleware allowing cross-origin requests from any origin.
+func CORS() MiddlewareFunc {
+	return CORSWithConfig(CORSConfig{})
+}
+
+// CORSWithConfig returns a middleware handling cross-origin requests with the given configuration.
+// Preflight requests, i.e. OPTIONS requests with an Access-Control-Request-Method header,
+// are answered with a 204 No Content response without calling the next handlers.
+// Used with Router.Use, the middleware also answers preflight requests for paths
+// without an OPTIONS route, since the automatic OPTIONS and MethodNotAllowed handlers
+// run through the global middlewares.
+// Requests from origins that are not allowed are passed to the next handlers
+// without CORS headers.
+// CORSWithConfig panics if AllowCredentials is set and every origin is allowed,
+// since any site could then make credentialed requests.
+func CORSWithConfig(config CORSConfig) MiddlewareFunc {
+	if len(config.AllowOrigins) == 0 && config.AllowOriginFunc == nil {
+		config.AllowOrigins = []string{"*"}
+	}
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = []string{
+			http.MethodGet, http.MethodHead, http.MethodPut,
+			http.MethodPatch, http.MethodPost, http.MethodDelete,
+		}
+	}
+
+	allowAll := false
+	for _, origin := range config.AllowOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+	}
+	if allowAll && config.AllowCredentials {
+		panic("gort: CORS cannot allow credentials for every origin, list the allowed origins instead")
+	}
+
+	allowMethods := strings.Join(config.AllowMethods, ", ")
+	allowHeaders := strings.Join(config.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(config.ExposeHeaders, ", ")
+
+	maxAge := ""
+	if config.MaxAge > 0 {
+		maxAge = strconv.Itoa(config.MaxAge)
+	} else if config.MaxAge < 0 {
+		maxAge = "0"
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			req := ctx.Request()
+			header := ctx.Writer.Header()
+			origin := req.Header.Get("Origin")
+			preflight := req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+
+			header.Add("Vary", "Origin")
+			if preflight {
+				header.Add("Vary", "Access-Control-Request-Method")
+				header.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" || !(allowAll || allowOrigin(config, origin)) {
+				return next(ctx)
+			}
+
+			if allowAll {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+			}
+			if config.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if exposeHeaders != "" {
+					header.Set("Access-Control-Expose-Headers", exposeHeaders)
+				}
+				return next(ctx)
+			}
+
+			header.Set("Access-Control-Allow-Methods", allowMethods)
+			if allowHeaders != "" {
+				header.Set("Access-Control-Allow-Headers", allowHeaders)
+			} else if requested := req.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				header.Set("Access-Control-Allow-Headers", requested)
+			}
+			if maxAge != "" {
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+
+			ctx.Writer.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+	}
+}
+
+// allowOrigin reports whether the origin matches one of the allowed origins or the AllowOriginFunc.
+func allowOrigin(config CORSConfig, origin string) bool {
+	for _, allowed := range config.AllowOrigins {
+		if matchOrigin(allowed, origin) {
+			return true
+		}
+	}
+	return config.AllowOriginFunc != nil && config.AllowOriginFunc(origin)
+}
+
+// matchOrigin reports whether the origin matches the pattern,
+// which may contain a "*" wildcard matching any sequence of characters.
+func matchOrigin(pattern, origin string) bool {
+	prefix, suffix, wildcard := strings.Cut(pattern, "*")
+	if !wildcard {
+		return strings.EqualFold(pattern, origin)
+	}
+	return len(origin) >= len(prefix)+len(suffix) &&
+		strings.EqualFold(origin[:len(prefix)], prefix) &&
+		strings.EqualFold(origin[len(origin)-len(suffix):], suffix)
+}
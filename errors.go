@@ -0,0 +1,94 @@
+package gort
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrorHandlerFunc handles an error returned by a HandlerFunc.
+type ErrorHandlerFunc func(*Context, error)
+
+// HTTPError is an error carrying the HTTP status code and message
+// that should be sent to the client, and optionally the error that caused it.
+type HTTPError struct {
+	Code    int
+	Message string
+	Err     error
+}
+
+// NewHTTPError creates a new HTTPError with the given status code.
+// If no message is given, the standard status text is used.
+func NewHTTPError(code int, message ...string) *HTTPError {
+	e := &HTTPError{
+		Code:    code,
+		Message: http.StatusText(code),
+	}
+	if len(message) > 0 {
+		e.Message = strings.Join(message, " ")
+	}
+	return e
+}
+
+// Wrap sets the underlying cause of the error and returns the error.
+func (e *HTTPError) Wrap(err error) *HTTPError {
+	e.Err = err
+	return e
+}
+
+// Error implements the error interface.
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("code=%d, message=%s, err=%v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("code=%d, message=%s", e.Code, e.Message)
+}
+
+// Unwrap returns the underlying cause of the error.
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultErrorHandler is the default ErrorHandler of the Router.
+// Errors that are not an *HTTPError are reported to the client as 500 Internal Server Error
+// without exposing their message.
+// The response body is JSON if the client accepts it, plain text otherwise.
+// If the error wraps ValidationErrors, the body lists every field error.
+// Server errors (5xx) are logged with the ERROR level. Client errors (4xx), such as
+// requests for missing routes, are not logged; use the AccessLog middleware to record them.
+// If the response has already been written, the error is only logged.
+func DefaultErrorHandler(ctx *Context, err error) {
+	var he *HTTPError
+	if !errors.As(err, &he) {
+		he = NewHTTPError(http.StatusInternalServerError).Wrap(err)
+	}
+
+	if ctx.Logger != nil && he.Code >= http.StatusInternalServerError {
+		ctx.Logger.Error(fmt.Sprintf("%s %s: %v", ctx.request.Method, ctx.request.URL.Path, err))
+	}
+
+	if ctx.Writer.Committed() {
+		return
+	}
+
+	var verrs ValidationErrors
+	errors.As(err, &verrs)
+
+	if strings.Contains(ctx.GetHeader("Accept"), "application/json") {
+		body := map[string]any{"code": he.Code, "message": he.Message}
+		if len(verrs) > 0 {
+			body["errors"] = verrs
+		}
+		ctx.JSON(he.Code, body)
+		return
+	}
+
+	message := he.Message
+	for _, fe := range verrs {
+		message += "\n" + fe.Error()
+	}
+
+	ctx.SetHeader("Content-Type", "text/plain; charset=utf-8")
+	ctx.WriteString(he.Code, message)
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"log"
 	"net/http"
 
 	"github.com/aboxofsox/gort"
@@ -24,15 +23,6 @@ func userMiddleware(users map[string]string) gort.MiddlewareFunc {
 	}
 }
 
-func loggingMiddleware(next gort.HandlerFunc) gort.HandlerFunc {
-	return func(c *gort.Context) error {
-		r := c.Request()
-		log.Println(r.Method, r.URL.Path)
-
-		return next(c)
-	}
-}
-
 func main() {
 	router := gort.New()
 
@@ -40,7 +30,7 @@ func main() {
 		"123": "bar",
 	}
 
-	router.Use(userMiddleware(users), loggingMiddleware)
+	router.Use(gort.AccessLog(gort.CombinedLogFormat), userMiddleware(users))
 
 	router.AddRoute(http.MethodGet, "/users/:id", func(c *gort.Context) error {
 		return c.WriteString(http.StatusOK, "the user middleware is responsable for setting the X-User header")
@@ -1,25 +1,142 @@
 package gort
 
 import (
-	"os"
-	"path/filepath"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
 )
 
-// readDir recursively reads the directory specified by 'dir' and calls the 'handler' function for each file or directory found.
-// The 'handler' function is called with the full path of the file or directory and its corresponding os.DirEntry.
-// If an error occurs while reading the directory, the function returns without processing any files.
-func readDir(dir string, handler func(path string, entry os.DirEntry)) {
-	files, err := os.ReadDir(dir)
+// StaticFS serves the files of fsys under the given prefix, e.g. "/assets/css/site.css"
+// for the file "css/site.css" with the "/assets" prefix. Files are read on demand,
+// so fsys can be an embed.FS, or a sub-tree of one returned by fs.Sub.
+//
+// A request for a directory serves its index.html file, and is redirected to the
+// path with a trailing slash so that relative links resolve. Directories are not listed.
+// Request paths are cleaned before being resolved, so that they cannot escape fsys.
+// Missing files are reported as a 404 Not Found *HTTPError.
+//
+// Responses carry an ETag derived from the modification time and the size of the file,
+// the modification time as Last-Modified, and the Cache-Control header of the matching
+// Router.CachePolicies. Conditional requests are answered with 304 Not Modified.
+// The files of a fsys without modification times, such as an embed.FS, are hashed
+// when StaticFS is called instead, and their ETag is derived from their content.
+//
+// Files implementing io.Seeker, such as the files of os.DirFS and embed.FS, are streamed
+// with support for Range and If-Range requests: a single range is answered with
+// 206 Partial Content, several ranges with a multipart/byteranges body.
+//
+// StaticFS registers a GET route with the "*filepath" catch-all segment,
+// and returns an error if the root of fsys cannot be read.
+func (r *Router) StaticFS(prefix string, fsys fs.FS) error {
+	if _, err := fs.Stat(fsys, "."); err != nil {
+		return err
+	}
+
+	etags := &etagCache{}
+	etags.hashAll(fsys)
+	r.GET(strings.TrimSuffix(prefix, "/")+"/*filepath", func(ctx *Context) error {
+		return serveFS(ctx, fsys, etags, ctx.Param("filepath"))
+	})
+	return nil
+}
+
+// serveFS writes the file of fsys with the given slash-separated name to the response.
+func serveFS(ctx *Context, fsys fs.FS, etags *etagCache, name string) error {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		return NewHTTPError(http.StatusNotFound)
+	}
+
+	f, info, err := openFile(fsys, name)
 	if err != nil {
-		return
+		return err
 	}
 
-	for _, file := range files {
-		fp := filepath.Join(dir, file.Name())
-		if file.IsDir() {
-			readDir(fp, handler)
-		} else {
-			handler(fp, file)
+	if info.IsDir() {
+		f.Close()
+
+		req := ctx.Request()
+		if !strings.HasSuffix(req.URL.Path, "/") {
+			target := req.URL.Path + "/"
+			if req.URL.RawQuery != "" {
+				target += "?" + req.URL.RawQuery
+			}
+			http.Redirect(ctx.Writer, req, target, http.StatusMovedPermanently)
+			return nil
 		}
+
+		name = path.Join(name, "index.html")
+		if f, info, err = openFile(fsys, name); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			f.Close()
+			return NewHTTPError(http.StatusNotFound)
+		}
+	}
+	defer f.Close()
+
+	etag, err := etags.etag(fsys, name, info)
+	if err != nil {
+		return fileError(err)
+	}
+	if writeCacheHeaders(ctx, name, etag, info.ModTime()) {
+		return nil
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if content, ok := f.(io.ReadSeeker); ok {
+		return serveContent(ctx, content, info.Size(), contentType, etag, info.ModTime())
+	}
+
+	header := ctx.Writer.Header()
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	header.Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+
+	ctx.Writer.WriteHeader(http.StatusOK)
+	if ctx.Request().Method == http.MethodHead {
+		return nil
+	}
+	_, err = io.Copy(ctx.Writer, f)
+	return err
+}
+
+// openFile opens the named file of fsys and returns its information.
+// Errors are converted to *HTTPError: 404 Not Found if the file does not exist,
+// 403 Forbidden if it cannot be accessed, 500 Internal Server Error otherwise.
+func openFile(fsys fs.FS, name string) (fs.File, fs.FileInfo, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, fileError(err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, fileError(err)
+	}
+
+	return f, info, nil
+}
+
+// fileError converts an error opening a file to an *HTTPError.
+func fileError(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return NewHTTPError(http.StatusNotFound).Wrap(err)
+	case errors.Is(err, fs.ErrPermission):
+		return NewHTTPError(http.StatusForbidden).Wrap(err)
+	default:
+		return NewHTTPError(http.StatusInternalServerError).Wrap(err)
 	}
 }
@@ -1,18 +1,48 @@
 package gort
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 type Server struct {
 	Router *Router
 	Store  *Store
+
+	// ReadTimeout, ReadHeaderTimeout, WriteTimeout, IdleTimeout and MaxHeaderBytes
+	// configure the underlying http.Server. A zero value means no limit,
+	// or the net/http default for MaxHeaderBytes.
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+
+	// HandleSignals makes Start and StartTLS shut the server down gracefully
+	// when the process receives SIGINT or SIGTERM.
+	HandleSignals bool
+
+	// ShutdownTimeout is how long a signal-triggered shutdown waits for in-flight
+	// requests to complete. A zero value waits indefinitely.
+	ShutdownTimeout time.Duration
+
+	mu     sync.Mutex
+	server *http.Server
 }
 
 func NewServer() *Server {
 	return &Server{
-		Router: New(),
-		Store:  NewStore(),
+		Router:            New(),
+		Store:             NewStore(),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		ShutdownTimeout:   10 * time.Second,
 	}
 }
 
@@ -22,13 +52,101 @@ func (s *Server) Handle(method, pattern string, handler HandlerFunc) {
 }
 
 // Start starts the server on the given address.
+// It blocks until the server is shut down, in which case it returns nil.
 func (s *Server) Start(addr string) error {
-	return http.ListenAndServe(addr, s.Router)
+	return s.serve(addr, func(srv *http.Server) error {
+		return srv.ListenAndServe()
+	})
 }
 
 // StartTLS starts the server on the given address with TLS.
+// It blocks until the server is shut down, in which case it returns nil.
 func (s *Server) StartTLS(addr, certFile, keyFile string) error {
-	return http.ListenAndServeTLS(addr, certFile, keyFile, s.Router)
+	return s.serve(addr, func(srv *http.Server) error {
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	})
+}
+
+// Shutdown gracefully shuts down the server without interrupting active connections.
+// It waits for in-flight requests to complete or for the context to be done, whichever comes first.
+// Shutdown does nothing if the server has not been started.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	srv := s.server
+	s.mu.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+
+	return srv.Shutdown(ctx)
+}
+
+// serve creates the underlying http.Server and runs listen with it.
+// If HandleSignals is set, it waits for the signal-triggered shutdown to complete before returning.
+func (s *Server) serve(addr string, listen func(*http.Server) error) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Router,
+		ReadTimeout:       s.ReadTimeout,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		MaxHeaderBytes:    s.MaxHeaderBytes,
+	}
+
+	s.mu.Lock()
+	s.server = srv
+	s.mu.Unlock()
+
+	var (
+		stopped  chan struct{}
+		shutdown chan error
+	)
+	if s.HandleSignals {
+		stopped = make(chan struct{})
+		shutdown = make(chan error, 1)
+		go s.trapSignals(srv, stopped, shutdown)
+	}
+
+	err := listen(srv)
+	if stopped != nil {
+		close(stopped)
+	}
+
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	if shutdown != nil {
+		return <-shutdown
+	}
+
+	return nil
+}
+
+// trapSignals shuts the server down when the process receives SIGINT or SIGTERM
+// and sends the result of the shutdown on done.
+// If the server stops for another reason first, it sends nil on done.
+func (s *Server) trapSignals(srv *http.Server, stopped <-chan struct{}, done chan<- error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+	case <-stopped:
+		done <- nil
+		return
+	}
+
+	shutdownCtx := context.Background()
+	if s.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, s.ShutdownTimeout)
+		defer cancel()
+	}
+
+	done <- srv.Shutdown(shutdownCtx)
 }
 
 // registerRoute registers a route for the given method and pattern.
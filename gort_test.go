@@ -2,12 +2,32 @@ package gort
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"encoding/json"
+	"encoding/xml"
 	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	_ "net/http/pprof"
+	"net/netip"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 // https://github.com/vishr/web-framework-benchmark/blob/master/router_test.go
@@ -593,6 +613,73 @@ func BenchmarkGortPokeAPI(b *testing.B) {
 	benchmarkRoutes(b, g, pokeAPI)
 }
 
+func noopMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx *Context) error {
+		return next(ctx)
+	}
+}
+
+func loadGortMiddlewares(g *Router) {
+	g.Use(noopMiddleware, noopMiddleware, noopMiddleware)
+}
+
+func BenchmarkGortMiddlewareStatic(b *testing.B) {
+	g := New()
+	loadGortMiddlewares(g)
+	loadGortRoutes(g, static)
+	benchmarkRoutes(b, g, static)
+}
+
+func BenchmarkGortMiddlewareGitHubAPI(b *testing.B) {
+	g := New()
+	loadGortMiddlewares(g)
+	loadGortRoutes(g, githubAPI)
+	benchmarkRoutes(b, g, githubAPI)
+}
+
+func BenchmarkGortMiddlewareParseAPI(b *testing.B) {
+	g := New()
+	loadGortRoutes(g, parseAPI)
+	loadGortMiddlewares(g)
+	benchmarkRoutes(b, g, parseAPI)
+}
+
+// BenchmarkGortMiddlewareChain compares calling a chain composed once at registration
+// with composing the same chain on every request.
+func BenchmarkGortMiddlewareChain(b *testing.B) {
+	middlewares := []MiddlewareFunc{noopMiddleware, noopMiddleware, noopMiddleware}
+	route := &Route{
+		Handler:     func(ctx *Context) error { return nil },
+		Middlewares: middlewares,
+	}
+	ctx := &Context{}
+
+	b.Run("Compiled", func(b *testing.B) {
+		g := New()
+		g.Use(middlewares...)
+		g.compile(route)
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			route.chain(ctx)
+		}
+	})
+
+	b.Run("PerRequest", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			handler := route.Handler
+			for j := len(route.Middlewares) - 1; j >= 0; j-- {
+				handler = route.Middlewares[j](handler)
+			}
+			for j := len(middlewares) - 1; j >= 0; j-- {
+				handler = middlewares[j](handler)
+			}
+			handler(ctx)
+		}
+	})
+}
+
 func TestGort(t *testing.T) {
 	router := New()
 
@@ -654,7 +741,7 @@ func TestGort(t *testing.T) {
 			return
 		}
 
-		route := router.routes.find("/users/foo")
+		route, _ := router.routes.find("/users/foo", http.MethodGet, false)
 
 		if route == nil {
 			t.Error("unexpected nil route")
@@ -1162,3 +1249,2236 @@ func TestGort(t *testing.T) {
 		}
 	})
 }
+
+func TestMethods(t *testing.T) {
+	router := New()
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		method := method
+		router.AddRoute(method, "/users/:id", func(ctx *Context) error {
+			return ctx.WriteString(http.StatusOK, method+" "+ctx.Param("id"))
+		})
+	}
+
+	t.Run("Dispatch", func(t *testing.T) {
+		for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(method, "/users/foo", nil))
+
+			if w.Code != http.StatusOK {
+				t.Errorf("expected status code to be %d, got %d", http.StatusOK, w.Code)
+				return
+			}
+
+			if w.Body.String() != method+" foo" {
+				t.Errorf("expected response body to be %q, got %q", method+" foo", w.Body.String())
+				return
+			}
+		}
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users/foo", nil))
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status code to be %d, got %d", http.StatusMethodNotAllowed, w.Code)
+			return
+		}
+
+		if allow := w.Header().Get("Allow"); allow != "DELETE, GET, HEAD, OPTIONS, PUT" {
+			t.Errorf("expected Allow header to be %q, got %q", "DELETE, GET, HEAD, OPTIONS, PUT", allow)
+			return
+		}
+	})
+}
+
+func TestErrorHandler(t *testing.T) {
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+
+	router.GET("/http-error", func(ctx *Context) error {
+		return NewHTTPError(http.StatusNotFound, "user not found")
+	})
+
+	router.GET("/error", func(ctx *Context) error {
+		return io.ErrUnexpectedEOF
+	})
+
+	t.Run("HTTPError", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/http-error", nil)
+		req.Header.Set("Accept", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status code to be %d, got %d", http.StatusNotFound, w.Code)
+			return
+		}
+
+		var body map[string]any
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if body["message"] != "user not found" {
+			t.Errorf("expected message to be %q, got %v", "user not found", body["message"])
+			return
+		}
+	})
+
+	t.Run("Untyped", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/error", nil))
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status code to be %d, got %d", http.StatusInternalServerError, w.Code)
+			return
+		}
+
+		if w.Body.String() != "Internal Server Error" {
+			t.Errorf("expected response body to be %q, got %q", "Internal Server Error", w.Body.String())
+			return
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		var got error
+		router.ErrorHandler = func(ctx *Context, err error) {
+			got = err
+			ctx.WriteString(http.StatusTeapot, "custom")
+		}
+		defer func() { router.ErrorHandler = DefaultErrorHandler }()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/error", nil))
+
+		if got != io.ErrUnexpectedEOF {
+			t.Errorf("expected error to be %v, got %v", io.ErrUnexpectedEOF, got)
+			return
+		}
+
+		if w.Code != http.StatusTeapot {
+			t.Errorf("expected status code to be %d, got %d", http.StatusTeapot, w.Code)
+			return
+		}
+	})
+}
+
+func TestWildcard(t *testing.T) {
+	router := New()
+
+	router.GET("/files/*path", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, "files "+ctx.Param("path"))
+	})
+
+	router.GET("/files/readme", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, "readme")
+	})
+
+	router.GET("/app/*", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, "app "+ctx.Param("*"))
+	})
+
+	router.GET("/app/:page/edit", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, "edit "+ctx.Param("page"))
+	})
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/files/a/b/c.txt", "files a/b/c.txt"},
+		{"/files/readme", "readme"},
+		{"/files/readme/more", "files readme/more"},
+		{"/files/", "files "},
+		{"/app", "app "},
+		{"/app/settings", "app settings"},
+		{"/app/settings/edit", "edit settings"},
+		{"/app/settings/view", "app settings/view"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status code to be %d, got %d", tt.path, http.StatusOK, w.Code)
+			continue
+		}
+
+		if w.Body.String() != tt.body {
+			t.Errorf("%s: expected response body to be %q, got %q", tt.path, tt.body, w.Body.String())
+		}
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected AddRoute to panic")
+			}
+		}()
+
+		router.GET("/files/*path/info", func(ctx *Context) error { return nil })
+	})
+}
+
+func TestBacktracking(t *testing.T) {
+	router := New()
+
+	router.GET("/users/new", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, "new")
+	})
+
+	router.GET("/users/:id/posts", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, "posts "+ctx.Param("id"))
+	})
+
+	router.POST("/users/:id", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, "update "+ctx.Param("id"))
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+		allow  string
+	}{
+		{http.MethodGet, "/users/new", http.StatusOK, "new", ""},
+		{http.MethodGet, "/users/new/posts", http.StatusOK, "posts new", ""},
+		{http.MethodGet, "/users/foo/posts", http.StatusOK, "posts foo", ""},
+		{http.MethodPost, "/users/new", http.StatusOK, "update new", ""},
+		{http.MethodPost, "/users/foo", http.StatusOK, "update foo", ""},
+		{http.MethodGet, "/users/foo", http.StatusMethodNotAllowed, "", "OPTIONS, POST"},
+		{http.MethodDelete, "/users/new", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS"},
+		{http.MethodGet, "/users/foo/bar", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if w.Code != tt.code {
+			t.Errorf("%s %s: expected status code to be %d, got %d", tt.method, tt.path, tt.code, w.Code)
+			continue
+		}
+
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s %s: expected response body to be %q, got %q", tt.method, tt.path, tt.body, w.Body.String())
+		}
+
+		if allow := w.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: expected Allow header to be %q, got %q", tt.method, tt.path, tt.allow, allow)
+		}
+	}
+
+	t.Run("Conflict", func(t *testing.T) {
+		err := router.routes.add(&Route{Method: http.MethodGet, Pattern: "/users/:name"})
+		if err == nil {
+			t.Error("expected conflicting parameter names to return an error")
+		}
+	})
+}
+
+func TestGroupMiddleware(t *testing.T) {
+	router := New()
+
+	trace := func(name string) MiddlewareFunc {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx *Context) error {
+				ctx.Writer.Header().Add("X-Trace", name)
+				return next(ctx)
+			}
+		}
+	}
+
+	router.Use(trace("global"))
+
+	admin := router.Group("/admin")
+	admin.Use(trace("admin"))
+
+	users := admin.Group("/users")
+	users.Use(trace("users"))
+
+	users.GET("/:id", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, ctx.Param("id"))
+	}, trace("route"))
+
+	router.GET("/public", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, "public")
+	})
+
+	tests := []struct {
+		path  string
+		trace []string
+	}{
+		{"/admin/users/foo", []string{"global", "admin", "users", "route"}},
+		{"/public", []string{"global"}},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status code to be %d, got %d", tt.path, http.StatusOK, w.Code)
+			continue
+		}
+
+		got := w.Header().Values("X-Trace")
+		if len(got) != len(tt.trace) {
+			t.Errorf("%s: expected trace to be %v, got %v", tt.path, tt.trace, got)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != tt.trace[i] {
+				t.Errorf("%s: expected trace to be %v, got %v", tt.path, tt.trace, got)
+				break
+			}
+		}
+	}
+
+	t.Run("UseAfterRoutes", func(t *testing.T) {
+		router := New()
+		router.Logger = NewLogger(io.Discard)
+
+		admin := router.Group("/admin")
+		admin.GET("/x", func(ctx *Context) error {
+			return ctx.WriteString(http.StatusOK, "x")
+		}, trace("route"))
+
+		reports := admin.Group("/reports")
+		reports.GET("/y", func(ctx *Context) error {
+			return ctx.WriteString(http.StatusOK, "y")
+		})
+
+		router.GET("/public", func(ctx *Context) error {
+			return ctx.WriteString(http.StatusOK, "public")
+		})
+
+		admin.Use(func(next HandlerFunc) HandlerFunc {
+			return func(ctx *Context) error {
+				return NewHTTPError(http.StatusUnauthorized)
+			}
+		})
+
+		tests := []struct {
+			path string
+			code int
+		}{
+			{"/admin/x", http.StatusUnauthorized},
+			{"/admin/reports/y", http.StatusUnauthorized},
+			{"/public", http.StatusOK},
+		}
+
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.code {
+				t.Errorf("%s: expected status code to be %d, got %d", tt.path, tt.code, w.Code)
+			}
+		}
+
+		route, _ := router.Match(http.MethodGet, "/admin/x")
+		if len(route.Middlewares) != 2 {
+			t.Errorf("expected /admin/x to have 2 middlewares, got %d", len(route.Middlewares))
+		}
+	})
+}
+
+func TestServerShutdown(t *testing.T) {
+	s := NewServer()
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("expected Shutdown before Start to return nil, got %v", err)
+		return
+	}
+
+	t.Run("InFlight", func(t *testing.T) {
+		s := NewServer()
+		serveInFlight(t, s, func(<-chan struct{}) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return s.Shutdown(ctx)
+		})
+	})
+
+	t.Run("HandleSignals", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("signals cannot be sent to the process on windows")
+		}
+
+		// Keep the test process alive if a signal arrives before the server traps it.
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt)
+		defer signal.Stop(sigs)
+
+		s := NewServer()
+		s.HandleSignals = true
+		serveInFlight(t, s, func(shuttingDown <-chan struct{}) error {
+			p, err := os.FindProcess(os.Getpid())
+			if err != nil {
+				return err
+			}
+			// The server may not be trapping signals yet: send them until it shuts down.
+			for {
+				if err := p.Signal(os.Interrupt); err != nil {
+					return err
+				}
+				select {
+				case <-shuttingDown:
+					return nil
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		})
+	})
+}
+
+// serveInFlight starts s, sends it a request blocking in its handler, and calls shutdown
+// while the request is in flight. It checks that the shutdown waits for the request,
+// that the request completes with 200 OK once released, and that Start returns nil.
+// shuttingDown is closed when the shutdown of the server begins.
+func serveInFlight(t *testing.T, s *Server, shutdown func(shuttingDown <-chan struct{}) error) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	s.Router.Logger = NewLogger(io.Discard)
+	s.Router.GET("/slow", func(ctx *Context) error {
+		close(started)
+		<-release
+		return ctx.WriteString(http.StatusOK, "done")
+	})
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Start(addr)
+	}()
+
+	type result struct {
+		code int
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			resp, err := http.Get("http://" + addr + "/slow")
+			if err != nil {
+				if time.Now().Before(deadline) {
+					time.Sleep(10 * time.Millisecond)
+					continue
+				}
+				results <- result{err: err}
+				return
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			results <- result{code: resp.StatusCode, body: string(body), err: err}
+			return
+		}
+	}()
+
+	select {
+	case <-started:
+	case res := <-results:
+		t.Fatalf("expected the request to block in the handler, got %+v", res)
+	case err := <-errc:
+		t.Fatalf("expected Start to block, got %v", err)
+	}
+
+	shuttingDown := make(chan struct{})
+	s.mu.Lock()
+	s.server.RegisterOnShutdown(func() { close(shuttingDown) })
+	s.mu.Unlock()
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- shutdown(shuttingDown)
+	}()
+
+	select {
+	case <-shuttingDown:
+	case err := <-shutdownErr:
+		close(release)
+		t.Fatalf("expected the shutdown to begin, got %v", err)
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("expected the shutdown to begin")
+	}
+
+	// Shutdown waits for the in-flight request, and so does Start when it handles the signals.
+	if s.HandleSignals {
+		select {
+		case err := <-errc:
+			close(release)
+			t.Fatalf("expected Start to wait for the in-flight request, got %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+	} else {
+		select {
+		case err := <-shutdownErr:
+			close(release)
+			t.Fatalf("expected Shutdown to wait for the in-flight request, got %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	close(release)
+
+	if res := <-results; res.err != nil || res.code != http.StatusOK || res.body != "done" {
+		t.Errorf("expected the in-flight request to complete with %d %q, got %d %q (%v)", http.StatusOK, "done", res.code, res.body, res.err)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("expected the shutdown to succeed, got %v", err)
+	}
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("expected Start to return nil after the shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected Start to return after the shutdown")
+	}
+}
+
+func TestLogger(t *testing.T) {
+	t.Run("Concurrent", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := NewLogger(&buf)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					logger.Info("hello")
+				}
+			}()
+		}
+		wg.Wait()
+
+		if err := logger.Close(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if n := strings.Count(buf.String(), "\n"); n != 5000 {
+			t.Errorf("expected 5000 lines, got %d", n)
+			return
+		}
+
+		logger.Info("dropped")
+	})
+
+	t.Run("Level", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := NewLogger(&buf)
+		logger.SetLevel(WARNING)
+
+		logger.Debug("debug")
+		logger.Info("info")
+		logger.Warning("warning")
+		logger.Error("error")
+		logger.Flush()
+
+		out := buf.String()
+		if strings.Contains(out, "debug") || strings.Contains(out, "info") {
+			t.Errorf("expected DEBUG and INFO events to be discarded, got %q", out)
+			return
+		}
+
+		if !strings.Contains(out, "WARNING warning") || !strings.Contains(out, "ERROR error") {
+			t.Errorf("expected WARNING and ERROR events to be written, got %q", out)
+			return
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := NewLogger(&buf)
+		logger.SetEncoder(JSONEncoder{})
+
+		logger.With("request_id", "abc").Info("hello", "status", 200, Field{Key: "path", Value: "/users"})
+		logger.Flush()
+
+		var event map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := map[string]any{
+			"level":      "INFO",
+			"message":    "hello",
+			"request_id": "abc",
+			"status":     float64(200),
+			"path":       "/users",
+		}
+
+		for k, v := range expected {
+			if event[k] != v {
+				t.Errorf("expected %s to be %v, got %v", k, v, event[k])
+			}
+		}
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := NewLogger(&buf)
+
+		logger.Info("hello", "user", "foo bar", "id", 1)
+		logger.Flush()
+
+		if !strings.HasSuffix(buf.String(), "INFO hello user=\"foo bar\" id=1\n") {
+			t.Errorf("unexpected text encoding %q", buf.String())
+		}
+	})
+}
+
+func TestSlog(t *testing.T) {
+	t.Run("Adapter", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := NewSlogLogger(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+		logger.Info("info")
+		logger.Warning("warning", "user", "foo")
+		logger.Flush()
+
+		var record map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if record["level"] != "WARN" || record["msg"] != "warning" || record["user"] != "foo" {
+			t.Errorf("unexpected record %v", record)
+			return
+		}
+	})
+
+	t.Run("Handler", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := NewLogger(&buf)
+		logger.SetEncoder(JSONEncoder{})
+		logger.SetLevel(INFO)
+
+		router := New()
+		router.Logger = logger
+		router.GET("/slog", func(ctx *Context) error {
+			ctx.Slog().Debug("discarded")
+			ctx.Slog().WithGroup("user").Error("failed", "id", 42)
+			return ctx.WriteString(http.StatusOK, "ok")
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/slog", nil)
+		req = req.WithContext(WithLogAttrs(req.Context(), slog.String("request_id", "abc")))
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		logger.Flush()
+
+		var event map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := map[string]any{
+			"level":      "ERROR",
+			"message":    "failed",
+			"method":     http.MethodGet,
+			"path":       "/slog",
+			"request_id": "abc",
+			"user.id":    float64(42),
+		}
+
+		for k, v := range expected {
+			if event[k] != v {
+				t.Errorf("expected %s to be %v, got %v", k, v, event[k])
+			}
+		}
+	})
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf)
+	logger.SetEncoder(RawEncoder{})
+
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+	router.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}
+	router.GET("/users/:id", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusCreated, "hello")
+	}, AccessLogWithConfig(AccessLogConfig{Format: JSONLogFormat, Logger: logger}))
+	router.GET("/missing/:id", func(ctx *Context) error {
+		return NewHTTPError(http.StatusNotFound)
+	}, AccessLogWithConfig(AccessLogConfig{Format: CombinedLogFormat, Logger: logger}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/foo", nil)
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	logger.Flush()
+
+	var entry AccessLogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if entry.Status != http.StatusCreated || entry.Bytes != 5 || entry.Pattern != "/users/:id" || entry.ClientIP != "10.0.0.2" {
+		t.Errorf("unexpected entry %+v", entry)
+		return
+	}
+
+	buf.Reset()
+	req = httptest.NewRequest(http.MethodGet, "/missing/foo", nil)
+	req.Header.Set("User-Agent", "test")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	logger.Flush()
+
+	line := buf.String()
+	if !strings.HasPrefix(line, "192.0.2.1 - - [") || !strings.HasSuffix(line, "] \"GET /missing/foo HTTP/1.1\" 404 9 \"-\" \"test\"\n") {
+		t.Errorf("unexpected combined log line %q", line)
+	}
+}
+
+func TestResponseWriter(t *testing.T) {
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+
+	var status, size int
+	router.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			err := next(ctx)
+			status, size = ctx.Writer.Status(), ctx.Writer.Size()
+			return err
+		}
+	})
+
+	router.GET("/json", func(ctx *Context) error {
+		return ctx.JSON(http.StatusCreated, "ok")
+	})
+
+	router.GET("/flush", func(ctx *Context) error {
+		if _, ok := ctx.ResponseWriter().(http.Flusher); !ok {
+			return ctx.WriteString(http.StatusInternalServerError, "not a flusher")
+		}
+		ctx.Writer.Write([]byte("hello"))
+		ctx.Writer.Flush()
+		return ctx.WriteString(http.StatusOK, "world")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/json", nil))
+
+	if w.Code != http.StatusCreated || status != http.StatusCreated {
+		t.Errorf("expected status code to be %d, got %d and %d", http.StatusCreated, w.Code, status)
+		return
+	}
+
+	if size != len(`"ok"`) {
+		t.Errorf("expected size to be %d, got %d", len(`"ok"`), size)
+		return
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/flush", nil))
+
+	if !w.Flushed || w.Body.String() != "hello" {
+		t.Errorf("expected flushed response body to be hello, got %q", w.Body.String())
+		return
+	}
+
+	if status != http.StatusOK || size != len("hello") {
+		t.Errorf("expected status %d and size %d, got %d and %d", http.StatusOK, len("hello"), status, size)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	var buf bytes.Buffer
+	router := New()
+	router.Logger = NewLogger(&buf)
+
+	router.GET("/panic", func(ctx *Context) error {
+		panic("boom")
+	}, Recover())
+
+	router.GET("/panic-dev", func(ctx *Context) error {
+		panic("boom")
+	}, RecoverWithConfig(RecoverConfig{Development: true}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	router.Logger.Flush()
+
+	if w.Code != http.StatusInternalServerError || w.Body.String() != "Internal Server Error" {
+		t.Errorf("expected 500 Internal Server Error, got %d %q", w.Code, w.Body.String())
+		return
+	}
+
+	if !strings.Contains(buf.String(), "panic: boom") || !strings.Contains(buf.String(), "goroutine") {
+		t.Errorf("expected panic and stack trace to be logged, got %q", buf.String())
+		return
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic-dev", nil))
+
+	if w.Code != http.StatusInternalServerError || !strings.HasPrefix(w.Body.String(), "panic: boom\n\ngoroutine") {
+		t.Errorf("expected panic and stack trace in response, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	type user struct {
+		Name string `json:"name" xml:"name"`
+	}
+
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+	router.GET("/user", func(ctx *Context) error {
+		return ctx.Negotiate(http.StatusOK, user{Name: "foo"})
+	})
+
+	tests := []struct {
+		accept      string
+		code        int
+		contentType string
+	}{
+		{"", http.StatusOK, "application/json"},
+		{"application/json", http.StatusOK, "application/json"},
+		{"application/xml;q=0.9, application/json;q=0.8", http.StatusOK, "application/xml; charset=utf-8"},
+		{"text/*;q=0.5, text/html", http.StatusOK, "text/html"},
+		{"text/plain, */*;q=0.1", http.StatusOK, "text/plain; charset=utf-8"},
+		{"*/*", http.StatusOK, "application/json"},
+		{"image/png", http.StatusNotAcceptable, ""},
+		{"application/json;q=0, */*;q=0.5", http.StatusOK, "application/xml; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("%q: expected status code to be %d, got %d", tt.accept, tt.code, w.Code)
+			continue
+		}
+
+		if tt.contentType != "" && w.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%q: expected Content-Type to be %q, got %q", tt.accept, tt.contentType, w.Header().Get("Content-Type"))
+		}
+	}
+
+	t.Run("XML", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		req.Header.Set("Accept", "application/xml")
+		router.ServeHTTP(w, req)
+
+		expected := xml.Header + "<user>\n  <name>foo</name>\n</user>"
+		if w.Body.String() != expected {
+			t.Errorf("expected response body to be %q, got %q", expected, w.Body.String())
+		}
+	})
+}
+
+func TestBind(t *testing.T) {
+	type pagination struct {
+		Page int `query:"page"`
+	}
+
+	type request struct {
+		pagination
+		ID      int           `param:"id"`
+		Token   string        `header:"X-Token"`
+		Tags    []string      `query:"tag"`
+		Active  *bool         `query:"active"`
+		Since   time.Time     `query:"since" layout:"2006-01-02"`
+		Timeout time.Duration `query:"timeout"`
+		Name    string        `json:"name" form:"name"`
+		Age     uint8         `json:"age" form:"age"`
+	}
+
+	var got request
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+	router.POST("/users/:id", func(ctx *Context) error {
+		got = request{}
+		if err := ctx.Bind(&got); err != nil {
+			return err
+		}
+		return ctx.WriteString(http.StatusOK, "ok")
+	})
+
+	target := "/users/42?page=3&tag=a&tag=b&active=true&since=2024-01-02&timeout=1m30s"
+
+	t.Run("JSON", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"name":"foo","age":30}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Token", "secret")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status code to be %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			return
+		}
+
+		since := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+		if got.ID != 42 || got.Page != 3 || got.Token != "secret" || len(got.Tags) != 2 || got.Tags[1] != "b" ||
+			got.Active == nil || !*got.Active || !got.Since.Equal(since) || got.Timeout != 90*time.Second ||
+			got.Name != "foo" || got.Age != 30 {
+			t.Errorf("unexpected binding %+v", got)
+		}
+	})
+
+	t.Run("Form", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/users/42", strings.NewReader("name=bar&age=7"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || got.Name != "bar" || got.Age != 7 || got.ID != 42 {
+			t.Errorf("unexpected binding %d %+v", w.Code, got)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users/42?page=first", nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status code to be %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		if body := w.Body.String(); !strings.Contains(body, `invalid value for query "page"`) || strings.Contains(body, "strconv") {
+			t.Errorf("expected a client-facing message without the cause, got %q", body)
+		}
+
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/users/42", strings.NewReader(`{"name":`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		if body := w.Body.String(); w.Code != http.StatusBadRequest || !strings.Contains(body, "invalid request body") {
+			t.Errorf("expected %d with message %q, got %d %q", http.StatusBadRequest, "invalid request body", w.Code, body)
+		}
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodPost, "/users/42", strings.NewReader("name: foo"))
+		req.Header.Set("Content-Type", "application/yaml")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("expected status code to be %d, got %d", http.StatusUnsupportedMediaType, w.Code)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	type address struct {
+		City string `json:"city" validate:"required"`
+	}
+
+	type request struct {
+		Name    string   `json:"name" validate:"required,min=1,max=8"`
+		Email   string   `json:"email" validate:"required,email"`
+		Role    string   `json:"role" validate:"omitempty,oneof=admin user"`
+		Age     int      `json:"age" validate:"min=18"`
+		Code    string   `json:"code" validate:"omitempty,even"`
+		Address *address `json:"address"`
+	}
+
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+	router.RegisterValidator("even", func(field reflect.Value, _ string) bool {
+		return field.Len()%2 == 0
+	})
+	router.POST("/users", func(ctx *Context) error {
+		var req request
+		if err := ctx.Bind(&req); err != nil {
+			return err
+		}
+		return ctx.WriteString(http.StatusOK, "ok")
+	})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(`{"name":"foo","email":"foo@example.com","role":"admin","age":30,"code":"ab","address":{"city":"Paris"}}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status code to be %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		return
+	}
+
+	w = post(`{"name":"foobarbaz","email":"foo","role":"guest","age":12,"code":"abc","address":{}}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status code to be %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		return
+	}
+
+	var body struct {
+		Errors []FieldError `json:"errors"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := []string{"name max", "email email", "role oneof", "age min", "code even", "address.city required"}
+	if len(body.Errors) != len(expected) {
+		t.Errorf("expected %d field errors, got %v", len(expected), body.Errors)
+		return
+	}
+
+	for i, fe := range body.Errors {
+		if fe.Field+" "+fe.Rule != expected[i] {
+			t.Errorf("expected field error %q, got %q", expected[i], fe.Field+" "+fe.Rule)
+		}
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+
+	router.GET("/users/:id<int>", func(ctx *Context) error {
+		id, err := ctx.ParamInt("id")
+		if err != nil {
+			return err
+		}
+		return ctx.WriteString(http.StatusOK, "id "+strconv.Itoa(id+1))
+	})
+
+	router.GET("/users/:slug<[a-z-]+>", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, "slug "+ctx.Param("slug"))
+	})
+
+	router.GET("/users/:name", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, "name "+ctx.Param("name"))
+	})
+
+	router.GET("/orders/:uuid<uuid>", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, "order "+ctx.Param("uuid"))
+	})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users/41", http.StatusOK, "id 42"},
+		{"/users/john-doe", http.StatusOK, "slug john-doe"},
+		{"/users/John_Doe", http.StatusOK, "name John_Doe"},
+		{"/orders/123e4567-e89b-12d3-a456-426614174000", http.StatusOK, "order 123e4567-e89b-12d3-a456-426614174000"},
+		{"/orders/42", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if w.Code != tt.code {
+			t.Errorf("%s: expected status code to be %d, got %d", tt.path, tt.code, w.Code)
+			continue
+		}
+
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s: expected response body to be %q, got %q", tt.path, tt.body, w.Body.String())
+		}
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		patterns := []string{
+			"/users/:key<int>",
+			"/items/:id<[a-z>",
+			"/slugs/:slug<[a-z/]+>",
+			"/codes/:code<int",
+			"/names/:na<me>x",
+		}
+		for _, pattern := range patterns {
+			if err := router.routes.add(&Route{Method: http.MethodGet, Pattern: pattern}); err == nil {
+				t.Errorf("%s: expected an error", pattern)
+			}
+		}
+	})
+}
+
+func TestURL(t *testing.T) {
+	router := New()
+	handler := func(ctx *Context) error { return nil }
+
+	router.GET("/users/:id<int>/posts/:post", handler).Named("user-post")
+	router.GET("/files/*path", handler).Named("file")
+	api := router.Group("/api")
+	api.GET("/items/:name", handler).Named("item")
+
+	tests := []struct {
+		name   string
+		params []string
+		url    string
+		err    bool
+	}{
+		{"user-post", []string{"id", "42", "post", "hello world"}, "/users/42/posts/hello%20world", false},
+		{"file", []string{"path", "css/site main.css"}, "/files/css/site%20main.css", false},
+		{"item", []string{"name", "a/b"}, "/api/items/a%2Fb", false},
+		{"user-post", []string{"id", "42"}, "", true},
+		{"user-post", []string{"id", "abc", "post", "x"}, "", true},
+		{"user-post", []string{"id"}, "", true},
+		{"missing", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		url, err := router.URL(tt.name, tt.params...)
+		if (err != nil) != tt.err {
+			t.Errorf("URL(%q, %v) error = %v, want error %v", tt.name, tt.params, err, tt.err)
+			continue
+		}
+		if url != tt.url {
+			t.Errorf("URL(%q, %v) = %q, want %q", tt.name, tt.params, url, tt.url)
+		}
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	router := New()
+	handler := func(ctx *Context) error { return nil }
+
+	router.Use(noopMiddleware)
+	router.GET("/users/:id", handler).Named("user")
+	router.DELETE("/users/:id", handler, noopMiddleware)
+	router.GET("/", handler)
+	router.GET("/debug/routes", router.RoutesHandler())
+
+	routes := router.Routes()
+	var got []string
+	for _, route := range routes {
+		got = append(got, route.Method+" "+route.Pattern)
+	}
+	want := []string{"GET /", "GET /debug/routes", "DELETE /users/:id", "GET /users/:id"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Routes() = %v, want %v", got, want)
+	}
+
+	t.Run("Match", func(t *testing.T) {
+		route, params := router.Match(http.MethodDelete, "/users/42")
+		if route == nil || route.Method != http.MethodDelete || params["id"] != "42" {
+			t.Errorf("Match(DELETE, /users/42) = %v, %v", route, params)
+		}
+
+		if route, _ := router.Match(http.MethodPost, "/users/42"); route != nil {
+			t.Errorf("Match(POST, /users/42) = %v, want nil", route)
+		}
+		if route, _ := router.Match(http.MethodGet, "/missing"); route != nil {
+			t.Errorf("Match(GET, /missing) = %v, want nil", route)
+		}
+
+		other := New()
+		other.GET("/users/new", handler)
+		other.POST("/users/:id", handler)
+
+		route, params = other.Match(http.MethodPost, "/users/new")
+		if route == nil || route.Pattern != "/users/:id" || params["id"] != "new" {
+			t.Errorf("Match(POST, /users/new) = %v, %v, want /users/:id with id=new", route, params)
+		}
+		if route, _ := other.Match(http.MethodHead, "/users/new"); route == nil || route.Pattern != "/users/new" {
+			t.Errorf("Match(HEAD, /users/new) = %v, want /users/new", route)
+		}
+	})
+
+	t.Run("PrintRoutes", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := router.PrintRoutes(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 5 {
+			t.Fatalf("PrintRoutes wrote %d lines, want 5:\n%s", len(lines), buf.String())
+		}
+		if fields := strings.Fields(lines[4]); !reflect.DeepEqual(fields, []string{"GET", "/users/:id", "user", "1"}) {
+			t.Errorf("PrintRoutes line = %q", lines[4])
+		}
+		if fields := strings.Fields(lines[3]); fields[2] != "-" || fields[3] != "2" {
+			t.Errorf("PrintRoutes line = %q", lines[3])
+		}
+	})
+
+	t.Run("RoutesHandler", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/routes", nil))
+
+		var infos []routeInfo
+		if err := json.NewDecoder(w.Body).Decode(&infos); err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) != 4 || infos[3].Name != "user" {
+			t.Errorf("RoutesHandler = %+v", infos)
+		}
+	})
+}
+
+func TestNotFoundHandlers(t *testing.T) {
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+
+	var calls int
+	router.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			calls++
+			return next(ctx)
+		}
+	})
+
+	router.GET("/users/:id", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, ctx.Param("id"))
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		calls = 0
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+		req.Header.Set("Accept", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status code to be %d, got %d", http.StatusNotFound, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("expected JSON response, got %q", ct)
+		}
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users/42", nil))
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status code to be %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS" {
+			t.Errorf("expected Allow header to be %q, got %q", "GET, HEAD, OPTIONS", allow)
+		}
+
+		if calls != 2 {
+			t.Errorf("expected the global middleware to run 2 times, ran %d times", calls)
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		router.NotFound = func(ctx *Context) error {
+			return ctx.JSON(http.StatusNotFound, map[string]string{"error": "no route for " + ctx.Request().URL.Path})
+		}
+		router.MethodNotAllowed = func(ctx *Context) error {
+			return ctx.WriteString(http.StatusMethodNotAllowed, "allowed: "+ctx.Writer.Header().Get("Allow"))
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+		if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), `"error": "no route for /missing"`) {
+			t.Errorf("unexpected NotFound response: %d %q", w.Code, w.Body.String())
+		}
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/users/42", nil))
+
+		if w.Code != http.StatusMethodNotAllowed || w.Body.String() != "allowed: GET, HEAD, OPTIONS" {
+			t.Errorf("unexpected MethodNotAllowed response: %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Compiled", func(t *testing.T) {
+		router := New()
+		router.Logger = NewLogger(io.Discard)
+
+		var compiled int
+		router.Use(func(next HandlerFunc) HandlerFunc {
+			compiled++
+			return next
+		})
+		router.GET("/users/:id", func(ctx *Context) error { return nil })
+		compiled = 0
+
+		for _, req := range []*http.Request{
+			httptest.NewRequest(http.MethodGet, "/missing", nil),
+			httptest.NewRequest(http.MethodPost, "/users/42", nil),
+			httptest.NewRequest(http.MethodOptions, "/users/42", nil),
+		} {
+			router.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		if compiled != 0 {
+			t.Errorf("expected the middleware chains to be composed once, composed %d more times", compiled)
+		}
+	})
+
+	t.Run("NotLogged", func(t *testing.T) {
+		var buf bytes.Buffer
+		router := New()
+		router.Logger = NewLogger(&buf)
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/favicon.ico", nil))
+		router.Logger.Flush()
+
+		if buf.Len() != 0 {
+			t.Errorf("expected 404 responses not to be logged, got %q", buf.String())
+		}
+	})
+}
+
+func TestHeadOptions(t *testing.T) {
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+
+	var calls int
+	router.Use(func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			calls++
+			return next(ctx)
+		}
+	})
+
+	router.GET("/health", func(ctx *Context) error {
+		ctx.Writer.Header().Set("X-Health", "ok")
+		return ctx.WriteString(http.StatusOK, "ok")
+	})
+	router.POST("/health", func(ctx *Context) error { return nil })
+	router.GET("/items", func(ctx *Context) error { return ctx.WriteString(http.StatusOK, "items") })
+	router.HEAD("/items", func(ctx *Context) error {
+		ctx.Writer.Header().Set("X-Head", "explicit")
+		ctx.Writer.WriteHeader(http.StatusOK)
+		return nil
+	})
+	router.OPTIONS("/items", func(ctx *Context) error { return ctx.WriteString(http.StatusOK, "custom") })
+
+	t.Run("HEAD", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/health", nil))
+
+		if w.Code != http.StatusOK || w.Header().Get("X-Health") != "ok" {
+			t.Errorf("unexpected HEAD response: %d %v", w.Code, w.Header())
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("expected empty body, got %q", w.Body.String())
+		}
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/items", nil))
+		if w.Header().Get("X-Head") != "explicit" {
+			t.Errorf("expected the HEAD route to override the automatic handling")
+		}
+	})
+
+	t.Run("OPTIONS", func(t *testing.T) {
+		calls = 0
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/health", nil))
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("expected status code to be %d, got %d", http.StatusNoContent, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS, POST" {
+			t.Errorf("expected Allow header to be %q, got %q", "GET, HEAD, OPTIONS, POST", allow)
+		}
+		if calls != 1 {
+			t.Errorf("expected the global middleware to run once, ran %d times", calls)
+		}
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/items", nil))
+		if w.Body.String() != "custom" {
+			t.Errorf("expected the OPTIONS route to override the automatic handling, got %q", w.Body.String())
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		router.HandleHEAD = false
+		router.HandleOPTIONS = false
+		defer func() {
+			router.HandleHEAD = true
+			router.HandleOPTIONS = true
+		}()
+
+		for _, method := range []string{http.MethodHead, http.MethodOptions} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(method, "/health", nil))
+
+			if w.Code != http.StatusMethodNotAllowed {
+				t.Errorf("%s: expected status code to be %d, got %d", method, http.StatusMethodNotAllowed, w.Code)
+			}
+			if allow := w.Header().Get("Allow"); allow != "GET, POST" {
+				t.Errorf("%s: expected Allow header to be %q, got %q", method, "GET, POST", allow)
+			}
+		}
+	})
+}
+
+func TestCORS(t *testing.T) {
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+	router.Use(CORSWithConfig(CORSConfig{
+		AllowOrigins:     []string{"https://example.com", "https://*.example.org"},
+		AllowOriginFunc:  func(origin string) bool { return origin == "http://localhost:3000" },
+		AllowMethods:     []string{http.MethodGet, http.MethodPost},
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"X-Total-Count"},
+		MaxAge:           600,
+	}))
+
+	router.GET("/users", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, "users")
+	})
+
+	t.Run("Simple", func(t *testing.T) {
+		for _, origin := range []string{"https://example.com", "https://api.example.org", "http://localhost:3000"} {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set("Origin", origin)
+			router.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != origin {
+				t.Errorf("%s: expected Access-Control-Allow-Origin to be %q, got %q", origin, origin, got)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+				t.Errorf("%s: expected Access-Control-Allow-Credentials to be %q, got %q", origin, "true", got)
+			}
+			if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-Total-Count" {
+				t.Errorf("%s: expected Access-Control-Expose-Headers to be %q, got %q", origin, "X-Total-Count", got)
+			}
+			if w.Body.String() != "users" {
+				t.Errorf("%s: expected response body to be %q, got %q", origin, "users", w.Body.String())
+			}
+		}
+	})
+
+	t.Run("Disallowed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("Origin", "https://evil.com")
+		router.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("expected no Access-Control-Allow-Origin header, got %q", got)
+		}
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status code to be %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("Preflight", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodOptions, "/users", nil)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "Content-Type, Authorization")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("expected status code to be %d, got %d", http.StatusNoContent, w.Code)
+		}
+
+		headers := map[string]string{
+			"Access-Control-Allow-Origin":  "https://example.com",
+			"Access-Control-Allow-Methods": "GET, POST",
+			"Access-Control-Allow-Headers": "Content-Type, Authorization",
+			"Access-Control-Max-Age":       "600",
+		}
+		for name, want := range headers {
+			if got := w.Header().Get(name); got != want {
+				t.Errorf("expected %s to be %q, got %q", name, want, got)
+			}
+		}
+	})
+
+	t.Run("PreflightWithoutAutomaticOPTIONS", func(t *testing.T) {
+		router.HandleOPTIONS = false
+		defer func() { router.HandleOPTIONS = true }()
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodOptions, "/users", nil)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("expected status code to be %d, got %d", http.StatusNoContent, w.Code)
+		}
+	})
+
+	t.Run("AllowAll", func(t *testing.T) {
+		router := New()
+		router.Use(CORS())
+		router.GET("/", func(ctx *Context) error { return nil })
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Origin", "https://anywhere.com")
+		router.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("expected Access-Control-Allow-Origin to be %q, got %q", "*", got)
+		}
+	})
+
+	t.Run("CredentialsWithAllOrigins", func(t *testing.T) {
+		configs := []CORSConfig{
+			{AllowCredentials: true},
+			{AllowOrigins: []string{"https://example.com", "*"}, AllowCredentials: true},
+		}
+
+		for _, config := range configs {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("expected CORSWithConfig(%+v) to panic", config)
+					}
+				}()
+				CORSWithConfig(config)
+			}()
+		}
+	})
+}
+
+// identityEncoder is a ContentEncoder writing the body unchanged, for testing custom encoders.
+type identityEncoder struct{}
+
+func (identityEncoder) Encoding() string { return "x-identity" }
+
+func (identityEncoder) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("compress me ", 200)
+
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+	router.Use(Compress())
+
+	router.GET("/text", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, body)
+	})
+	router.GET("/small", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, "small")
+	})
+	router.GET("/image", func(ctx *Context) error {
+		ctx.Writer.Header().Set("Content-Type", "image/png")
+		return ctx.WriteString(http.StatusOK, body)
+	})
+	router.GET("/error", func(ctx *Context) error {
+		return NewHTTPError(http.StatusTeapot)
+	})
+
+	serve := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Gzip", func(t *testing.T) {
+		w := serve("/text", "gzip, deflate")
+
+		if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("expected Content-Encoding to be %q, got %q", "gzip", got)
+		}
+		if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("expected Vary to be %q, got %q", "Accept-Encoding", got)
+		}
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+			t.Errorf("expected Content-Type to be text/plain, got %q", w.Header().Get("Content-Type"))
+		}
+
+		zr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != body {
+			t.Errorf("unexpected decompressed body of %d bytes", len(data))
+		}
+	})
+
+	t.Run("Deflate", func(t *testing.T) {
+		w := serve("/text", "gzip;q=0.5, deflate")
+
+		if got := w.Header().Get("Content-Encoding"); got != "deflate" {
+			t.Fatalf("expected Content-Encoding to be %q, got %q", "deflate", got)
+		}
+
+		zr, err := zlib.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != body {
+			t.Errorf("unexpected decompressed body of %d bytes", len(data))
+		}
+	})
+
+	t.Run("Uncompressed", func(t *testing.T) {
+		tests := []struct {
+			path           string
+			acceptEncoding string
+			code           int
+			body           string
+		}{
+			{"/text", "", http.StatusOK, body},
+			{"/text", "br", http.StatusOK, body},
+			{"/text", "gzip;q=0", http.StatusOK, body},
+			{"/small", "gzip", http.StatusOK, "small"},
+			{"/image", "gzip", http.StatusOK, body},
+			{"/error", "gzip", http.StatusTeapot, "I'm a teapot"},
+		}
+
+		for _, tt := range tests {
+			w := serve(tt.path, tt.acceptEncoding)
+
+			if got := w.Header().Get("Content-Encoding"); got != "" {
+				t.Errorf("%s (%s): expected no Content-Encoding, got %q", tt.path, tt.acceptEncoding, got)
+			}
+			if w.Code != tt.code {
+				t.Errorf("%s (%s): expected status code to be %d, got %d", tt.path, tt.acceptEncoding, tt.code, w.Code)
+			}
+			if w.Body.String() != tt.body {
+				t.Errorf("%s (%s): unexpected body %q", tt.path, tt.acceptEncoding, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("HEAD", func(t *testing.T) {
+		if err := router.StaticFS("/static", fstest.MapFS{
+			"large.txt": {Data: []byte(body)},
+			"small.txt": {Data: []byte("small")},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, path := range []string{"/text", "/small", "/image", "/static/large.txt", "/static/small.txt"} {
+			responses := make(map[string]*httptest.ResponseRecorder)
+			for _, method := range []string{http.MethodGet, http.MethodHead} {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest(method, path, nil)
+				req.Header.Set("Accept-Encoding", "gzip")
+				router.ServeHTTP(w, req)
+				responses[method] = w
+			}
+
+			get, head := responses[http.MethodGet], responses[http.MethodHead]
+			for _, name := range []string{"Content-Encoding", "Content-Length", "Content-Type", "ETag", "Vary"} {
+				if got, want := head.Header().Get(name), get.Header().Get(name); got != want {
+					t.Errorf("%s: expected HEAD %s to be %q, got %q", path, name, want, got)
+				}
+			}
+			if head.Body.Len() != 0 {
+				t.Errorf("%s: expected HEAD body to be empty, got %d bytes", path, head.Body.Len())
+			}
+		}
+	})
+
+	t.Run("NotModified", func(t *testing.T) {
+		serve := func(ifNoneMatch string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/static/large.txt", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			if ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", ifNoneMatch)
+			}
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		etag := serve("").Header().Get("ETag")
+		if !strings.HasPrefix(etag, "W/") {
+			t.Fatalf("expected a weak ETag for the compressed response, got %q", etag)
+		}
+
+		w := serve(etag)
+		if w.Code != http.StatusNotModified {
+			t.Fatalf("expected status code to be %d, got %d", http.StatusNotModified, w.Code)
+		}
+		if got := w.Header().Get("ETag"); got != etag {
+			t.Errorf("expected 304 ETag to be %q, got %q", etag, got)
+		}
+	})
+
+	t.Run("Size", func(t *testing.T) {
+		var size int
+		router := New()
+		router.Use(func(next HandlerFunc) HandlerFunc {
+			return func(ctx *Context) error {
+				err := next(ctx)
+				size = ctx.Writer.Size()
+				return err
+			}
+		}, Compress())
+		router.GET("/", func(ctx *Context) error {
+			return ctx.WriteString(http.StatusOK, body)
+		})
+
+		for _, acceptEncoding := range []string{"gzip", ""} {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+			router.ServeHTTP(w, req)
+
+			if size != w.Body.Len() {
+				t.Errorf("Accept-Encoding %q: expected Size to be %d, got %d", acceptEncoding, w.Body.Len(), size)
+			}
+		}
+	})
+
+	t.Run("CustomEncoder", func(t *testing.T) {
+		router := New()
+		router.Use(CompressWithConfig(CompressConfig{
+			Encoders:  []ContentEncoder{identityEncoder{}},
+			MinLength: 1,
+		}))
+		router.GET("/", func(ctx *Context) error {
+			return ctx.WriteString(http.StatusOK, "hello")
+		})
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip, x-identity")
+		router.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Content-Encoding"); got != "x-identity" {
+			t.Errorf("expected Content-Encoding to be %q, got %q", "x-identity", got)
+		}
+		if w.Body.String() != "hello" {
+			t.Errorf("expected response body to be %q, got %q", "hello", w.Body.String())
+		}
+	})
+}
+
+func TestStaticFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":          {Data: []byte("<h1>home</h1>")},
+		"css/site.css":        {Data: []byte("body{}")},
+		"docs/index.html":     {Data: []byte("<h1>docs</h1>")},
+		"docs/guide/intro.md": {Data: []byte("# intro")},
+		"empty/.keep":         {Data: []byte{}},
+	}
+
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+	if err := router.StaticFS("/assets", fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path        string
+		code        int
+		body        string
+		contentType string
+	}{
+		{"/assets/", http.StatusOK, "<h1>home</h1>", "text/html; charset=utf-8"},
+		{"/assets/css/site.css", http.StatusOK, "body{}", "text/css; charset=utf-8"},
+		{"/assets/docs/", http.StatusOK, "<h1>docs</h1>", "text/html; charset=utf-8"},
+		{"/assets/docs/guide/intro.md", http.StatusOK, "# intro", ""},
+		{"/assets/docs", http.StatusMovedPermanently, "", ""},
+		{"/assets/empty/", http.StatusNotFound, "", ""},
+		{"/assets/missing.txt", http.StatusNotFound, "", ""},
+		{"/assets/../../etc/passwd", http.StatusNotFound, "", ""},
+		{"/assets/css/../../index.html", http.StatusOK, "<h1>home</h1>", ""},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if w.Code != tt.code {
+			t.Errorf("%s: expected status code to be %d, got %d", tt.path, tt.code, w.Code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		if w.Body.String() != tt.body {
+			t.Errorf("%s: expected response body to be %q, got %q", tt.path, tt.body, w.Body.String())
+		}
+		if tt.contentType != "" && w.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%s: expected Content-Type to be %q, got %q", tt.path, tt.contentType, w.Header().Get("Content-Type"))
+		}
+	}
+
+	t.Run("Redirect", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/assets/docs?v=1", nil))
+
+		if location := w.Header().Get("Location"); location != "/assets/docs/?v=1" {
+			t.Errorf("expected Location to be %q, got %q", "/assets/docs/?v=1", location)
+		}
+	})
+
+	t.Run("Static", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "public")
+		if err := os.MkdirAll(filepath.Join(dir, "js", "lib"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "js", "lib", "app.js"), []byte("app()"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		router := New()
+		if err := router.Static("/static", dir); err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/js/lib/app.js", nil))
+
+		if w.Code != http.StatusOK || w.Body.String() != "app()" {
+			t.Errorf("unexpected response: %d %q", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestStaticCache(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"app.js":     {Data: []byte("app()"), ModTime: modTime},
+		"index.html": {Data: []byte("<h1>home</h1>"), ModTime: modTime},
+	}
+
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+	router.CachePolicies = []CachePolicy{
+		{Extension: ".html", CacheControl: "no-cache"},
+		{Prefix: "/assets/", CacheControl: "public, max-age=3600"},
+	}
+	if err := router.StaticFS("/assets", fsys); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/assets/app.js", nil)
+	etag := w.Header().Get("ETag")
+	if want := `"17cb5b99f8638000-5"`; etag != want {
+		t.Fatalf("expected ETag derived from the modification time and size to be %q, got %q", want, etag)
+	}
+	if got := w.Header().Get("Last-Modified"); got != "Wed, 01 May 2024 12:00:00 GMT" {
+		t.Errorf("expected Last-Modified to be %q, got %q", "Wed, 01 May 2024 12:00:00 GMT", got)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+		t.Errorf("expected Cache-Control to be %q, got %q", "public, max-age=3600", got)
+	}
+	if got := serve("/assets/", nil).Header().Get("Cache-Control"); got != "no-cache" {
+		t.Errorf("expected Cache-Control of index.html to be %q, got %q", "no-cache", got)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		code    int
+	}{
+		{"IfNoneMatch", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"IfNoneMatchList", map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{"IfNoneMatchStar", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"IfNoneMatchStale", map[string]string{"If-None-Match": `"stale"`}, http.StatusOK},
+		{"IfModifiedSince", map[string]string{"If-Modified-Since": "Wed, 01 May 2024 12:00:00 GMT"}, http.StatusNotModified},
+		{"IfModifiedSinceOlder", map[string]string{"If-Modified-Since": "Tue, 30 Apr 2024 12:00:00 GMT"}, http.StatusOK},
+		{"IfNoneMatchPrecedence", map[string]string{"If-None-Match": `"stale"`, "If-Modified-Since": "Wed, 01 May 2024 12:00:00 GMT"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve("/assets/app.js", tt.headers)
+
+			if w.Code != tt.code {
+				t.Errorf("expected status code to be %d, got %d", tt.code, w.Code)
+			}
+			if tt.code == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("expected empty body, got %q", w.Body.String())
+			}
+			if w.Header().Get("ETag") != etag {
+				t.Errorf("expected ETag to be %q, got %q", etag, w.Header().Get("ETag"))
+			}
+		})
+	}
+
+	t.Run("Static", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "app.css"), []byte("body{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		router := New()
+		if err := router.Static("/static", dir); err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/app.css", nil))
+		etag := w.Header().Get("ETag")
+		if etag == "" || w.Header().Get("Last-Modified") == "" {
+			t.Fatalf("expected ETag and Last-Modified headers, got %v", w.Header())
+		}
+
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/static/app.css", nil)
+		req.Header.Set("If-None-Match", etag)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("expected status code to be %d, got %d", http.StatusNotModified, w.Code)
+		}
+	})
+}
+
+func TestStaticRange(t *testing.T) {
+	content := "0123456789abcdefghijklmnopqrstuvwxyz"
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+	if err := router.StaticFS("/files", fstest.MapFS{
+		"data.txt": {Data: []byte(content), ModTime: modTime},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/files/data.txt", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	etag := serve(nil).Header().Get("ETag")
+
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		code         int
+		body         string
+		contentRange string
+	}{
+		{"None", nil, http.StatusOK, content, ""},
+		{"Range", map[string]string{"Range": "bytes=0-4"}, http.StatusPartialContent, "01234", "bytes 0-4/36"},
+		{"OpenEnded", map[string]string{"Range": "bytes=30-"}, http.StatusPartialContent, "uvwxyz", "bytes 30-35/36"},
+		{"Suffix", map[string]string{"Range": "bytes=-3"}, http.StatusPartialContent, "xyz", "bytes 33-35/36"},
+		{"Clamped", map[string]string{"Range": "bytes=34-100"}, http.StatusPartialContent, "yz", "bytes 34-35/36"},
+		{"Unsatisfiable", map[string]string{"Range": "bytes=100-"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */36"},
+		{"Invalid", map[string]string{"Range": "bytes=5-1"}, http.StatusOK, content, ""},
+		{"UnknownUnit", map[string]string{"Range": "items=0-1"}, http.StatusOK, content, ""},
+		{"IfRangeETag", map[string]string{"Range": "bytes=0-1", "If-Range": etag}, http.StatusPartialContent, "01", "bytes 0-1/36"},
+		{"IfRangeStaleETag", map[string]string{"Range": "bytes=0-1", "If-Range": `"stale"`}, http.StatusOK, content, ""},
+		{"IfRangeWeakETag", map[string]string{"Range": "bytes=0-1", "If-Range": "W/" + etag}, http.StatusOK, content, ""},
+		{"IfRangeDate", map[string]string{"Range": "bytes=0-1", "If-Range": "Wed, 01 May 2024 12:00:00 GMT"}, http.StatusPartialContent, "01", "bytes 0-1/36"},
+		{"IfRangeStaleDate", map[string]string{"Range": "bytes=0-1", "If-Range": "Tue, 30 Apr 2024 12:00:00 GMT"}, http.StatusOK, content, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.headers)
+
+			if w.Code != tt.code {
+				t.Fatalf("expected status code to be %d, got %d", tt.code, w.Code)
+			}
+			if got := w.Header().Get("Content-Range"); got != tt.contentRange {
+				t.Errorf("expected Content-Range to be %q, got %q", tt.contentRange, got)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("expected response body to be %q, got %q", tt.body, w.Body.String())
+			}
+			if tt.body != "" && w.Header().Get("Content-Length") != strconv.Itoa(len(tt.body)) {
+				t.Errorf("expected Content-Length to be %d, got %q", len(tt.body), w.Header().Get("Content-Length"))
+			}
+			if got := w.Header().Get("Accept-Ranges"); got != "bytes" {
+				t.Errorf("expected Accept-Ranges to be %q, got %q", "bytes", got)
+			}
+		})
+	}
+
+	t.Run("Multipart", func(t *testing.T) {
+		w := serve(map[string]string{"Range": "bytes=0-2, 10-12"})
+
+		if w.Code != http.StatusPartialContent {
+			t.Fatalf("expected status code to be %d, got %d", http.StatusPartialContent, w.Code)
+		}
+		if w.Header().Get("Content-Length") != strconv.Itoa(w.Body.Len()) {
+			t.Errorf("expected Content-Length to be %d, got %q", w.Body.Len(), w.Header().Get("Content-Length"))
+		}
+
+		mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		if err != nil || mediaType != "multipart/byteranges" {
+			t.Fatalf("expected a multipart/byteranges body, got %q", w.Header().Get("Content-Type"))
+		}
+
+		want := []struct{ body, contentRange string }{
+			{"012", "bytes 0-2/36"},
+			{"abc", "bytes 10-12/36"},
+		}
+		mr := multipart.NewReader(w.Body, params["boundary"])
+		for i, part := range want {
+			p, err := mr.NextPart()
+			if err != nil {
+				t.Fatalf("part %d: %v", i, err)
+			}
+			data, _ := io.ReadAll(p)
+			if string(data) != part.body || p.Header.Get("Content-Range") != part.contentRange {
+				t.Errorf("part %d: got %q (%s), want %q (%s)", i, data, p.Header.Get("Content-Range"), part.body, part.contentRange)
+			}
+			if !strings.HasPrefix(p.Header.Get("Content-Type"), "text/plain") {
+				t.Errorf("part %d: expected Content-Type to be text/plain, got %q", i, p.Header.Get("Content-Type"))
+			}
+		}
+		if _, err := mr.NextPart(); err != io.EOF {
+			t.Errorf("expected 2 parts, got more")
+		}
+	})
+
+	t.Run("Static", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "video.mp4"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		router := New()
+		if err := router.Static("/media", dir); err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/media/video.mp4", nil)
+		req.Header.Set("Range", "bytes=10-15")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusPartialContent || w.Body.String() != "abcdef" {
+			t.Errorf("unexpected response: %d %q", w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("Content-Type"); got != "video/mp4" {
+			t.Errorf("expected Content-Type to be %q, got %q", "video/mp4", got)
+		}
+	})
+}
+
+// countingFS is a fs.FS counting the bytes read from its files.
+type countingFS struct {
+	fs.FS
+	read *int64
+}
+
+func (c countingFS) Open(name string) (fs.File, error) {
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &countingFile{File: f, read: c.read}, nil
+}
+
+func (c countingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(c.FS, name)
+}
+
+type countingFile struct {
+	fs.File
+	read *int64
+}
+
+func (f *countingFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	*f.read += int64(n)
+	return n, err
+}
+
+func (f *countingFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+func TestStaticHead(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 1<<20)
+
+	var read int64
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+	if err := router.StaticFS("/embedded", countingFS{
+		FS:   fstest.MapFS{"large.txt": {Data: content}},
+		read: &read,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.StaticFS("/files", countingFS{
+		FS:   fstest.MapFS{"large.txt": {Data: content, ModTime: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}},
+		read: &read,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path, rangeHeader string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, path := range []string{"/embedded/large.txt", "/files/large.txt"} {
+		var heads []*httptest.ResponseRecorder
+		for _, rangeHeader := range []string{"", "bytes=0-9", "bytes=0-9,100-109"} {
+			read = 0
+			head := serve(http.MethodHead, path, rangeHeader)
+			heads = append(heads, head)
+
+			if read > 512 {
+				t.Errorf("%s: HEAD (Range %q) read %d bytes of the file", path, rangeHeader, read)
+			}
+			if head.Body.Len() != 0 {
+				t.Errorf("%s: HEAD (Range %q): expected empty body, got %d bytes", path, rangeHeader, head.Body.Len())
+			}
+			if rangeHeader != "" && head.Code != http.StatusPartialContent {
+				t.Errorf("%s: HEAD (Range %q): expected status code to be %d, got %d", path, rangeHeader, http.StatusPartialContent, head.Code)
+			}
+		}
+
+		read = 0
+		if w := serve(http.MethodGet, path, "bytes=0-9"); w.Code != http.StatusPartialContent || read > 512 {
+			t.Errorf("%s: Range request: expected status code %d reading at most 512 bytes, got %d reading %d bytes", path, http.StatusPartialContent, w.Code, read)
+		}
+
+		get := serve(http.MethodGet, path, "")
+		for _, name := range []string{"Content-Length", "Content-Type", "ETag", "Accept-Ranges"} {
+			if heads[0].Header().Get(name) != get.Header().Get(name) {
+				t.Errorf("%s: expected HEAD %s to be %q, got %q", path, name, get.Header().Get(name), heads[0].Header().Get(name))
+			}
+		}
+		if get.Header().Get("ETag") == "" {
+			t.Errorf("%s: expected an ETag header", path)
+		}
+	}
+}
+
+func TestParamsMatchRoute(t *testing.T) {
+	router := New()
+	router.Logger = NewLogger(io.Discard)
+
+	router.GET("/p/:x<int>/*rest", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, ctx.Param("x")+" "+ctx.Param("rest"))
+	})
+	router.GET("/users/:id<int>", func(ctx *Context) error {
+		id, err := ctx.ParamInt("id")
+		if err != nil {
+			return err
+		}
+		return ctx.WriteString(http.StatusOK, strconv.Itoa(id))
+	})
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/p/5/a/b", "5 a/b"},
+		{"/p//5/a/b", "5 a/b"},
+		{"/p/5//a/b", "5 a/b"},
+		{"/p/5", "5 "},
+		{"/users/1", "1"},
+		{"/users/1/", "1"},
+		{"//users//1", "1"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if w.Code != http.StatusOK || w.Body.String() != tt.body {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.path, http.StatusOK, tt.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	router := New()
+	router.GET("/", func(ctx *Context) error {
+		return ctx.WriteString(http.StatusOK, ctx.RealIP())
+	})
+
+	tests := []struct {
+		name    string
+		trusted []string
+		remote  string
+		headers map[string]string
+		ip      string
+	}{
+		{"Untrusted", nil, "203.0.113.7:1234", map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Real-IP": "1.2.3.4"}, "203.0.113.7"},
+		{"UntrustedRemote", []string{"10.0.0.0/8"}, "203.0.113.7:1234", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "203.0.113.7"},
+		{"Trusted", []string{"10.0.0.0/8"}, "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "1.2.3.4"},
+		{"SpoofedChain", []string{"10.0.0.0/8"}, "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "6.6.6.6, 1.2.3.4, 10.0.0.2"}, "1.2.3.4"},
+		{"AllTrusted", []string{"10.0.0.0/8"}, "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"RealIP", []string{"10.0.0.0/8"}, "10.0.0.1:1234", map[string]string{"X-Real-IP": "1.2.3.4"}, "1.2.3.4"},
+		{"NoHeaders", []string{"10.0.0.0/8"}, "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"IPv6", []string{"fd00::/8"}, "[fd00::1]:1234", map[string]string{"X-Forwarded-For": "2001:db8::1"}, "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router.TrustedProxies = nil
+			for _, prefix := range tt.trusted {
+				router.TrustedProxies = append(router.TrustedProxies, netip.MustParsePrefix(prefix))
+			}
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Body.String() != tt.ip {
+				t.Errorf("expected RealIP to be %q, got %q", tt.ip, w.Body.String())
+			}
+		})
+	}
+}
@@ -3,26 +3,104 @@ package gort
 import "net/http"
 
 type Group struct {
-	prefix string
-	router *Router
+	prefix      string
+	router      *Router
+	parent      *Group
+	middlewares []MiddlewareFunc
 }
 
-func (g *Group) AddRoute(method, pattern string, handler HandlerFunc) {
-	g.router.AddRoute(method, g.prefix+pattern, handler)
+// Use adds middlewares to the group.
+// Group middlewares run after the router's global middlewares and before route middlewares.
+// They apply to the routes of the group and of its nested groups, including the routes
+// registered before the call. Like Router.Use, it recomposes the middleware chains of
+// those routes and must not be called while serving requests.
+func (g *Group) Use(middlewares ...MiddlewareFunc) {
+	g.middlewares = append(g.middlewares, middlewares...)
+
+	g.router.routes.each(func(route *Route) {
+		if !g.contains(route.group) {
+			return
+		}
+		route.Middlewares = route.group.with(route.middlewares)
+		g.router.compile(route)
+	})
+}
+
+// contains reports whether other is the group or one of its nested groups.
+func (g *Group) contains(other *Group) bool {
+	for ; other != nil; other = other.parent {
+		if other == g {
+			return true
+		}
+	}
+	return false
+}
+
+// Group creates a new group nested in the current group.
+// The nested group inherits the prefix and the middlewares of the current group.
+func (g *Group) Group(prefix string) *Group {
+	child := &Group{
+		prefix: g.prefix + prefix,
+		router: g.router,
+		parent: g,
+	}
+
+	g.router.Groups = append(g.router.Groups, child)
+
+	return child
+}
+
+// chain returns the middlewares of the group, including the ones inherited from its parents.
+func (g *Group) chain() []MiddlewareFunc {
+	if g.parent == nil {
+		return g.middlewares
+	}
+
+	parent := g.parent.chain()
+	middlewares := make([]MiddlewareFunc, 0, len(parent)+len(g.middlewares))
+	middlewares = append(middlewares, parent...)
+	return append(middlewares, g.middlewares...)
+}
+
+// with returns the middlewares of the group followed by the given route middlewares.
+func (g *Group) with(middlewares []MiddlewareFunc) []MiddlewareFunc {
+	chain := g.chain()
+	all := make([]MiddlewareFunc, 0, len(chain)+len(middlewares))
+	all = append(all, chain...)
+	return append(all, middlewares...)
+}
+
+func (g *Group) AddRoute(method, pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	route := g.router.AddRoute(method, g.prefix+pattern, handler, g.with(middlewares)...)
+	route.group = g
+	route.middlewares = middlewares
+	return route
+}
+
+func (g *Group) GET(pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.AddRoute(http.MethodGet, pattern, handler, middlewares...)
+}
+
+func (g *Group) POST(pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.AddRoute(http.MethodPost, pattern, handler, middlewares...)
+}
+
+func (g *Group) PUT(pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.AddRoute(http.MethodPut, pattern, handler, middlewares...)
 }
 
-func (g *Group) GET(pattern string, handler HandlerFunc) {
-	g.AddRoute(http.MethodGet, pattern, handler)
+func (g *Group) DELETE(pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.AddRoute(http.MethodDelete, pattern, handler, middlewares...)
 }
 
-func (g *Group) POST(pattern string, handler HandlerFunc) {
-	g.AddRoute(http.MethodPost, pattern, handler)
+func (g *Group) PATCH(pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.AddRoute(http.MethodPatch, pattern, handler, middlewares...)
 }
 
-func (g *Group) PUT(pattern string, handler HandlerFunc) {
-	g.AddRoute(http.MethodPut, pattern, handler)
+func (g *Group) HEAD(pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.AddRoute(http.MethodHead, pattern, handler, middlewares...)
 }
 
-func (g *Group) DELETE(pattern string, handler HandlerFunc) {
-	g.AddRoute(http.MethodDelete, pattern, handler)
+func (g *Group) OPTIONS(pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return g.AddRoute(http.MethodOptions, pattern, handler, middlewares...)
 }
@@ -1,9 +1,14 @@
 package gort
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -23,66 +28,338 @@ var Levels = map[Level]string{
 	DEBUG:   "DEBUG",
 }
 
-type Logger struct {
-	w      io.Writer
-	events chan Event
+// severity returns the rank of the level used for filtering.
+// DEBUG is the least severe level and ERROR the most severe.
+func (l Level) severity() int {
+	if l == DEBUG {
+		return -1
+	}
+	return int(l)
+}
+
+// bufferSize is the number of events a Logger queues before Log blocks.
+const bufferSize = 1024
+
+// Field is a key/value pair attached to an Event.
+type Field struct {
+	Key   string
+	Value any
 }
 
+// Event is a single log entry.
 type Event struct {
-	Timestamp string
+	Timestamp time.Time
 	Level     Level
 	Message   string
+	Fields    []Field
 }
 
 func (e Event) String() string {
-	return fmt.Sprintf("[%s] %s %s", e.Timestamp, Levels[e.Level], e.Message)
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("[%s] %s %s", e.Timestamp.Format(time.ANSIC), Levels[e.Level], e.Message))
+	for _, f := range e.Fields {
+		b.WriteByte(' ')
+		b.WriteString(f.Key)
+		b.WriteByte('=')
+		b.WriteString(formatValue(f.Value))
+	}
+	return b.String()
+}
+
+// formatValue formats a field value for the text encoding, quoting it if necessary.
+func formatValue(v any) string {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	case time.Time:
+		s = v.Format(time.RFC3339)
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// LogEncoder encodes events written by a Logger.
+type LogEncoder interface {
+	Encode(w io.Writer, e Event) error
+}
+
+// TextEncoder encodes events as single lines of text:
+// the timestamp, the level, the message and the fields as key=value pairs.
+type TextEncoder struct{}
+
+func (TextEncoder) Encode(w io.Writer, e Event) error {
+	_, err := io.WriteString(w, e.String()+"\n")
+	return err
+}
+
+// RawEncoder encodes events as their message only, one per line.
+// It is suited to loggers writing preformatted lines, such as access logs.
+type RawEncoder struct{}
+
+func (RawEncoder) Encode(w io.Writer, e Event) error {
+	_, err := io.WriteString(w, e.Message+"\n")
+	return err
+}
+
+// JSONEncoder encodes events as JSON objects, one per line.
+// The fields are written as top-level keys after "time", "level" and "message".
+type JSONEncoder struct{}
+
+func (JSONEncoder) Encode(w io.Writer, e Event) error {
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeJSON(&buf, e.Timestamp.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(&buf, Levels[e.Level])
+	buf.WriteString(`,"message":`)
+	writeJSON(&buf, e.Message)
+	for _, f := range e.Fields {
+		buf.WriteByte(',')
+		writeJSON(&buf, f.Key)
+		buf.WriteByte(':')
+		if err, ok := f.Value.(error); ok {
+			writeJSON(&buf, err.Error())
+		} else {
+			writeJSON(&buf, f.Value)
+		}
+	}
+	buf.WriteString("}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeJSON writes the JSON encoding of v to buf, or its string representation if it cannot be encoded.
+func writeJSON(buf *bytes.Buffer, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(data)
+}
+
+// Logger is an asynchronous, leveled and structured logger.
+// Events are queued and written by a background goroutine,
+// so a Logger is safe for concurrent use. Call Close to flush
+// the queued events and stop the goroutine.
+type Logger struct {
+	core   *loggerCore
+	fields []Field
+}
+
+// loggerCore is the state shared by a Logger and the loggers derived from it with With.
+// mu guards the level and the closed state, encMu guards the writer and the encoder.
+// They are separate so that the writing goroutine never waits on a caller blocked on a full queue.
+type loggerCore struct {
+	mu       sync.RWMutex
+	minLevel Level
+	closed   bool
+
+	encMu   sync.Mutex
+	w       io.Writer
+	encoder LogEncoder
+
+	events chan logEntry
+	done   chan struct{}
+}
+
+// logEntry is an item of the event queue.
+// It holds either an event to write or a channel to close once the preceding events are written.
+type logEntry struct {
+	event   Event
+	flushed chan struct{}
 }
 
+// NewLogger creates a Logger writing text encoded events to w.
 func NewLogger(w io.Writer) *Logger {
-	logger := &Logger{
-		w:      w,
-		events: make(chan Event),
+	core := &loggerCore{
+		w:        w,
+		encoder:  TextEncoder{},
+		minLevel: DEBUG,
+		events:   make(chan logEntry, bufferSize),
+		done:     make(chan struct{}),
 	}
 
-	go logger.run()
+	go core.run()
 
-	return logger
+	return &Logger{core: core}
 }
 
-func (l *Logger) run() {
-	select {
-	case event := <-l.events:
-		l.w.Write([]byte(event.String()))
+func (c *loggerCore) run() {
+	defer close(c.done)
+
+	for entry := range c.events {
+		if entry.flushed != nil {
+			close(entry.flushed)
+			continue
+		}
+
+		c.encMu.Lock()
+		c.encoder.Encode(c.w, entry.event)
+		c.encMu.Unlock()
 	}
 }
 
-func (l *Logger) Log(level Level, message string) {
-	l.events <- Event{
-		Timestamp: time.Now().Format(time.ANSIC),
-		Level:     level,
-		Message:   message + "\n",
+// SetLevel sets the minimum level of the events written by the logger.
+// From least to most severe, the levels are DEBUG, INFO, WARNING and ERROR.
+func (l *Logger) SetLevel(level Level) {
+	l.core.mu.Lock()
+	defer l.core.mu.Unlock()
+	l.core.minLevel = level
+}
+
+// SetEncoder sets the encoder used to write events, e.g. TextEncoder or JSONEncoder.
+func (l *Logger) SetEncoder(encoder LogEncoder) {
+	l.core.encMu.Lock()
+	defer l.core.encMu.Unlock()
+	l.core.encoder = encoder
+}
+
+// Enabled reports whether events of the given level are written by the logger.
+func (l *Logger) Enabled(level Level) bool {
+	l.core.mu.RLock()
+	defer l.core.mu.RUnlock()
+	return !l.core.closed && level.severity() >= l.core.minLevel.severity()
+}
+
+// With returns a Logger that adds the given fields to every event.
+// The returned Logger shares the queue, writer and settings of the current one.
+// The arguments are either Field values or alternating keys and values.
+func (l *Logger) With(args ...any) *Logger {
+	fields := make([]Field, 0, len(l.fields)+len(args))
+	fields = append(fields, l.fields...)
+	return &Logger{
+		core:   l.core,
+		fields: appendFields(fields, args),
 	}
 }
 
-func (l *Logger) Info(message string) {
-	l.Log(INFO, message)
+// Log queues an event with the given level, message and fields.
+// The arguments are either Field values or alternating keys and values.
+// Events below the minimum level, or logged after Close, are discarded.
+// Log blocks if the queue is full.
+func (l *Logger) Log(level Level, message string, args ...any) {
+	var fields []Field
+	if len(args) > 0 {
+		fields = appendFields(nil, args)
+	}
+	l.log(time.Now(), level, message, fields)
 }
 
-func (l *Logger) Warning(message string) {
-	l.Log(WARNING, message)
+// log queues an event with the given timestamp, level, message and fields,
+// in addition to the fields of the logger.
+func (l *Logger) log(timestamp time.Time, level Level, message string, fields []Field) {
+	l.core.mu.RLock()
+	defer l.core.mu.RUnlock()
+
+	if l.core.closed || level.severity() < l.core.minLevel.severity() {
+		return
+	}
+
+	if len(l.fields) > 0 {
+		fields = append(append(make([]Field, 0, len(l.fields)+len(fields)), l.fields...), fields...)
+	}
+
+	l.core.events <- logEntry{event: Event{
+		Timestamp: timestamp,
+		Level:     level,
+		Message:   message,
+		Fields:    fields,
+	}}
 }
 
-func (l *Logger) Error(message string) {
-	l.Log(ERROR, message)
+func (l *Logger) Info(message string, args ...any) {
+	l.Log(INFO, message, args...)
 }
 
-func (l *Logger) Debug(message string) {
+func (l *Logger) Warning(message string, args ...any) {
+	l.Log(WARNING, message, args...)
+}
+
+func (l *Logger) Error(message string, args ...any) {
+	l.Log(ERROR, message, args...)
+}
+
+// Debug logs the message followed by the stack trace of the calling goroutine.
+func (l *Logger) Debug(message string, args ...any) {
+	if !l.Enabled(DEBUG) {
+		return
+	}
 	stack := getStacktrace()
-	l.Log(DEBUG, message+"\n"+stack)
+	l.Log(DEBUG, message+"\n"+stack, args...)
 }
 
+// Flush blocks until the events queued before the call are written.
+func (l *Logger) Flush() {
+	l.core.mu.RLock()
+	if l.core.closed {
+		l.core.mu.RUnlock()
+		return
+	}
+	flushed := make(chan struct{})
+	l.core.events <- logEntry{flushed: flushed}
+	l.core.mu.RUnlock()
+
+	<-flushed
+}
+
+// Close writes the queued events and stops the logger.
+// Closing a Logger also closes the loggers derived from it with With.
+func (l *Logger) Close() error {
+	l.core.mu.Lock()
+	if l.core.closed {
+		l.core.mu.Unlock()
+		return nil
+	}
+	l.core.closed = true
+	close(l.core.events)
+	l.core.mu.Unlock()
+
+	<-l.core.done
+	return nil
+}
+
+// appendFields appends the fields described by args to fields.
+// Field values are appended as is, other values are read as alternating keys and values.
+// A key without a value is stored with the "!BADKEY" key.
+func appendFields(fields []Field, args []any) []Field {
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i].(type) {
+		case Field:
+			fields = append(fields, arg)
+		case string:
+			if i+1 == len(args) {
+				fields = append(fields, Field{Key: "!BADKEY", Value: arg})
+				continue
+			}
+			fields = append(fields, Field{Key: arg, Value: args[i+1]})
+			i++
+		default:
+			fields = append(fields, Field{Key: "!BADKEY", Value: arg})
+		}
+	}
+	return fields
+}
+
+// maxStacktraceSize is the maximum size of a stack trace returned by getStacktrace.
+const maxStacktraceSize = 64 << 10
+
+// getStacktrace returns the stack trace of the calling goroutine, truncated to maxStacktraceSize bytes.
 func getStacktrace() string {
 	buf := make([]byte, 1024)
-	n := runtime.Stack(buf, false)
-	return string(buf[0:n])
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) || len(buf) >= maxStacktraceSize {
+			return string(buf[0:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
 }
@@ -0,0 +1,136 @@
+package gort
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	MIMEApplicationJSON = "application/json"
+	MIMEApplicationXML  = "application/xml"
+	MIMETextXML         = "text/xml"
+	MIMETextPlain       = "text/plain"
+	MIMETextHTML        = "text/html"
+)
+
+// negotiateOffers are the media types Negotiate can render, in order of preference.
+var negotiateOffers = []string{
+	MIMEApplicationJSON,
+	MIMEApplicationXML,
+	MIMETextXML,
+	MIMETextPlain,
+	MIMETextHTML,
+}
+
+// Negotiate writes data in the format preferred by the client according to the Accept header.
+// It renders JSON, XML, plain text or HTML; plain text and HTML are the fmt.Sprint representation
+// of data, escaped for HTML. When the client accepts several formats with the same quality,
+// they are preferred in that order, and JSON is used if there is no Accept header.
+// If the client accepts none of them, Negotiate returns a 406 Not Acceptable *HTTPError.
+func (ctx *Context) Negotiate(statusCode int, data any) error {
+	ctx.Writer.Header().Add("Vary", "Accept")
+
+	switch ctx.Accepts(negotiateOffers...) {
+	case MIMEApplicationJSON:
+		return ctx.JSON(statusCode, data)
+	case MIMEApplicationXML, MIMETextXML:
+		return ctx.XML(statusCode, data)
+	case MIMETextPlain:
+		ctx.SetHeader("Content-Type", "text/plain; charset=utf-8")
+		return ctx.WriteString(statusCode, fmt.Sprint(data))
+	case MIMETextHTML:
+		return ctx.HTML(statusCode, html.EscapeString(fmt.Sprint(data)))
+	default:
+		return NewHTTPError(http.StatusNotAcceptable)
+	}
+}
+
+// Accepts returns the offered media type best matching the Accept header of the request,
+// or an empty string if none is acceptable.
+// Offers with the same quality are preferred in the given order.
+// If the request has no Accept header, the first offer is returned.
+func (ctx *Context) Accepts(offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	header := ctx.request.Header.Get("Accept")
+	if header == "" {
+		return offers[0]
+	}
+
+	ranges := parseAccept(header)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// acceptRange is a media range of an Accept header.
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// parseAccept parses the media ranges of an Accept header.
+// Media type parameters other than q are ignored.
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+
+		r := acceptRange{typ: typ, subtype: subtype, q: 1}
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.ToLower(key) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(value, 64); err == nil && q >= 0 && q <= 1 {
+				r.q = q
+			}
+		}
+
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// acceptQuality returns the quality of the offer, taken from the most specific matching range.
+// It returns 0 if no range matches.
+func acceptQuality(ranges []acceptRange, offer string) float64 {
+	typ, subtype, _ := strings.Cut(strings.ToLower(offer), "/")
+
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
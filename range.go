@@ -0,0 +1,238 @@
+package gort
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errUnsatisfiable is returned by parseRange when none of the ranges overlaps the content.
+var errUnsatisfiable = errors.New("gort: range not satisfiable")
+
+// httpRange is a byte range of a Range header, resolved against the content size.
+type httpRange struct {
+	start, length int64
+}
+
+// contentRange returns the value of the Content-Range header of the range.
+func (r httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// mimeHeader returns the header of the part of a multipart/byteranges body holding the range.
+func (r httpRange) mimeHeader(contentType string, size int64) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Type":  {contentType},
+		"Content-Range": {r.contentRange(size)},
+	}
+}
+
+// serveContent writes content, of the given size, to the response, honoring the Range
+// and If-Range headers of GET and HEAD requests. The content is streamed: only the
+// requested ranges are read, seeking to their start.
+//
+// A single range is answered with 206 Partial Content and a Content-Range header,
+// several ranges with 206 Partial Content and a multipart/byteranges body.
+// Ranges that cannot be satisfied are reported as a 416 Range Not Satisfiable *HTTPError,
+// while invalid Range headers, and ranges whose total length exceeds the content size,
+// are ignored and the whole content is sent.
+// If-Range is satisfied by the strong etag or the exact modTime, otherwise the whole content is sent.
+// If contentType is empty, it is sniffed from the beginning of the content.
+// HEAD requests get the same headers as GET requests, without reading the content.
+func serveContent(ctx *Context, content io.ReadSeeker, size int64, contentType, etag string, modTime time.Time) error {
+	header := ctx.Writer.Header()
+	req := ctx.Request()
+
+	if contentType == "" {
+		var buf [512]byte
+		n, _ := io.ReadFull(content, buf[:])
+		contentType = http.DetectContentType(buf[:n])
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("Accept-Ranges", "bytes")
+
+	var ranges []httpRange
+	if rangeHeader := req.Header.Get("Range"); rangeHeader != "" &&
+		(req.Method == http.MethodGet || req.Method == http.MethodHead) &&
+		checkIfRange(req, etag, modTime) {
+
+		var err error
+		ranges, err = parseRange(rangeHeader, size)
+		if errors.Is(err, errUnsatisfiable) {
+			header.Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+			return NewHTTPError(http.StatusRequestedRangeNotSatisfiable)
+		}
+		if err != nil || rangesLength(ranges) > size {
+			ranges = nil
+		}
+	}
+
+	switch len(ranges) {
+	case 0:
+		header.Set("Content-Length", strconv.FormatInt(size, 10))
+		ctx.Writer.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodHead {
+			return nil
+		}
+		_, err := io.Copy(ctx.Writer, content)
+		return err
+	case 1:
+		ra := ranges[0]
+		header.Set("Content-Range", ra.contentRange(size))
+		header.Set("Content-Length", strconv.FormatInt(ra.length, 10))
+		ctx.Writer.WriteHeader(http.StatusPartialContent)
+		if req.Method == http.MethodHead {
+			return nil
+		}
+		if _, err := content.Seek(ra.start, io.SeekStart); err != nil {
+			return err
+		}
+		_, err := io.CopyN(ctx.Writer, content, ra.length)
+		return err
+	}
+
+	mw := multipart.NewWriter(ctx.Writer)
+	header.Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	header.Set("Content-Length", strconv.FormatInt(multipartLength(ranges, contentType, size, mw.Boundary()), 10))
+	ctx.Writer.WriteHeader(http.StatusPartialContent)
+	if req.Method == http.MethodHead {
+		return nil
+	}
+
+	for _, ra := range ranges {
+		part, err := mw.CreatePart(ra.mimeHeader(contentType, size))
+		if err != nil {
+			return err
+		}
+		if _, err := content.Seek(ra.start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(part, content, ra.length); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// checkIfRange reports whether the Range header of the request applies,
+// i.e. whether the request has no If-Range header or its validator matches:
+// an entity tag must match etag with the strong comparison, a date must equal modTime.
+func checkIfRange(req *http.Request, etag string, modTime time.Time) bool {
+	ifRange := req.Header.Get("If-Range")
+	if ifRange == "" {
+		return true
+	}
+
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return !strings.HasPrefix(ifRange, "W/") && !strings.HasPrefix(etag, "W/") && ifRange == etag
+	}
+
+	t, err := http.ParseTime(ifRange)
+	return err == nil && !isZeroTime(modTime) && modTime.Truncate(time.Second).Equal(t)
+}
+
+// parseRange parses a Range header of the bytes unit against the content size, e.g.
+// "bytes=0-499", "bytes=500-", "bytes=-500" or "bytes=0-0,-1".
+// Ranges starting after the end of the content are dropped, and errUnsatisfiable is returned
+// if none remains. Any other malformed header returns an error.
+func parseRange(header string, size int64) ([]httpRange, error) {
+	specs, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return nil, errors.New("gort: invalid range unit")
+	}
+
+	var ranges []httpRange
+	unsatisfiable := false
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, fmt.Errorf("gort: invalid range %q", spec)
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+		var ra httpRange
+		if first == "" {
+			// A suffix range, e.g. "-500" for the last 500 bytes.
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("gort: invalid range %q", spec)
+			}
+			if n == 0 || size == 0 {
+				unsatisfiable = true
+				continue
+			}
+			n = min(n, size)
+			ra = httpRange{start: size - n, length: n}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, fmt.Errorf("gort: invalid range %q", spec)
+			}
+			end := size - 1
+			if last != "" {
+				if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+					return nil, fmt.Errorf("gort: invalid range %q", spec)
+				}
+			}
+			if start >= size {
+				unsatisfiable = true
+				continue
+			}
+			end = min(end, size-1)
+			ra = httpRange{start: start, length: end - start + 1}
+		}
+		ranges = append(ranges, ra)
+	}
+
+	if len(ranges) == 0 {
+		if unsatisfiable {
+			return nil, errUnsatisfiable
+		}
+		return nil, errors.New("gort: empty range")
+	}
+	return ranges, nil
+}
+
+// rangesLength returns the total length of the ranges.
+func rangesLength(ranges []httpRange) int64 {
+	var n int64
+	for _, ra := range ranges {
+		n += ra.length
+	}
+	return n
+}
+
+// multipartLength returns the length of the multipart/byteranges body holding the ranges.
+func multipartLength(ranges []httpRange, contentType string, size int64, boundary string) int64 {
+	var w countingWriter
+	mw := multipart.NewWriter(&w)
+	mw.SetBoundary(boundary)
+	for _, ra := range ranges {
+		mw.CreatePart(ra.mimeHeader(contentType, size))
+		w += countingWriter(ra.length)
+	}
+	mw.Close()
+	return int64(w)
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,72 @@
+package gort
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RecoverConfig configures the Recover middleware.
+type RecoverConfig struct {
+	// Development includes the panic value and the stack trace in the response.
+	// It must not be enabled in production, as it exposes internal details to clients.
+	Development bool
+}
+
+// PanicError is the error returned by the Recover middleware when a handler panics.
+type PanicError struct {
+	Value any    // Value is the value passed to panic.
+	Stack string // Stack is the stack trace of the panicking goroutine.
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Recover returns a middleware recovering from panics in the next handlers.
+func Recover() MiddlewareFunc {
+	return RecoverWithConfig(RecoverConfig{})
+}
+
+// RecoverWithConfig returns a middleware recovering from panics in the next handlers
+// with the given configuration.
+// The panic value and the stack trace are logged with the ERROR level, and a 500 Internal Server Error
+// wrapping a *PanicError is returned to the router's ErrorHandler.
+// Panics with http.ErrAbortHandler are propagated, so that net/http aborts the response.
+func RecoverWithConfig(config RecoverConfig) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) (err error) {
+			defer func() {
+				value := recover()
+				if value == nil {
+					return
+				}
+				if value == http.ErrAbortHandler {
+					panic(value)
+				}
+
+				pe := &PanicError{
+					Value: value,
+					Stack: getStacktrace(),
+				}
+
+				if ctx.Logger != nil {
+					ctx.Logger.Log(ERROR, pe.Error()+"\n"+pe.Stack)
+				}
+
+				he := NewHTTPError(http.StatusInternalServerError).Wrap(pe)
+				if config.Development {
+					he.Message = pe.Error() + "\n\n" + pe.Stack
+				}
+				err = he
+			}()
+
+			return next(ctx)
+		}
+	}
+}
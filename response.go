@@ -0,0 +1,151 @@
+package gort
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter wraps a http.ResponseWriter and records the status code,
+// the number of bytes written and whether the response has been committed,
+// i.e. whether the header has been sent.
+// It implements http.Flusher, http.Hijacker and http.Pusher by delegating
+// to the wrapped writer when it supports them.
+type ResponseWriter struct {
+	http.ResponseWriter
+	status    int
+	size      int
+	committed bool
+	discard   bool // discard drops the body, e.g. when a GET route answers a HEAD request.
+}
+
+// NewResponseWriter wraps w in a ResponseWriter.
+// If w is already a *ResponseWriter, it is returned as is.
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	if rw, ok := w.(*ResponseWriter); ok {
+		return rw
+	}
+	return &ResponseWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+	}
+}
+
+// WriteHeader sends the response header with the given status code.
+// Calls after the response has been committed are ignored.
+func (w *ResponseWriter) WriteHeader(statusCode int) {
+	if w.committed {
+		return
+	}
+	w.status = statusCode
+	w.committed = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write writes data to the response body, committing the response with
+// a 200 OK status code if it has not been committed yet.
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if !w.committed {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discard {
+		w.discarded(b)
+		return len(b), nil
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// discarded reports the discarded bytes b of the body to the wrapped writer, if it records them,
+// e.g. so that the Compress middleware answers HEAD requests like GET requests.
+func (w *ResponseWriter) discarded(b []byte) {
+	if d, ok := w.ResponseWriter.(interface{ discarded([]byte) }); ok {
+		d.discarded(b)
+	}
+}
+
+// discardWriter is an io.Writer reporting the bytes written to it as discarded by w.
+type discardWriter struct {
+	w *ResponseWriter
+}
+
+func (d discardWriter) Write(b []byte) (int, error) {
+	d.w.discarded(b)
+	return len(b), nil
+}
+
+// ReadFrom copies the content of r to the response body,
+// allowing the wrapped writer to use an optimized copy, e.g. sendfile.
+func (w *ResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.committed {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discard {
+		return io.Copy(discardWriter{w}, r)
+	}
+	n, err := io.Copy(w.ResponseWriter, r)
+	w.size += int(n)
+	return n, err
+}
+
+// Status returns the status code of the response.
+// It is 200 OK until a different status code is written.
+func (w *ResponseWriter) Status() int {
+	return w.status
+}
+
+// Size returns the number of bytes written to the response body.
+// Once the Compress middleware returns, it is the number of bytes sent after compression.
+func (w *ResponseWriter) Size() int {
+	return w.size
+}
+
+// Committed reports whether the response header has been sent.
+func (w *ResponseWriter) Committed() bool {
+	return w.committed
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for use with http.ResponseController.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush sends any buffered data to the client.
+// It does nothing if the wrapped writer does not implement http.Flusher.
+func (w *ResponseWriter) Flush() {
+	flusher, ok := w.ResponseWriter.(http.Flusher)
+	if !ok {
+		return
+	}
+	if !w.committed {
+		w.WriteHeader(http.StatusOK)
+	}
+	flusher.Flush()
+}
+
+// Hijack lets the caller take over the connection.
+// It returns an error if the wrapped writer does not implement http.Hijacker.
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("gort: response writer does not implement http.Hijacker")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		w.committed = true
+	}
+	return conn, rw, err
+}
+
+// Push initiates an HTTP/2 server push.
+// It returns http.ErrNotSupported if the wrapped writer does not implement http.Pusher.
+func (w *ResponseWriter) Push(target string, opts *http.PushOptions) error {
+	pusher, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return pusher.Push(target, opts)
+}
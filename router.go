@@ -1,20 +1,25 @@
 package gort
 
 import (
-	"log"
 	"net/http"
+	"net/netip"
 	"os"
-	"path/filepath"
-	"strings"
 )
 
 type HandlerFunc func(*Context) error
 type MiddlewareFunc func(HandlerFunc) HandlerFunc
 
 type Route struct {
-	Method  string
-	Pattern string
-	Handler HandlerFunc
+	Method      string
+	Pattern     string
+	Name        string // Name is the name given to the route with Named, used by Router.URL.
+	Handler     HandlerFunc
+	Middlewares []MiddlewareFunc // Middlewares are the group and route middlewares applied to the Handler.
+
+	chain       HandlerFunc // chain is the Handler wrapped by the global, group and route middlewares.
+	router      *Router
+	group       *Group           // group is the group the route was registered on, if any.
+	middlewares []MiddlewareFunc // middlewares are the route's own middlewares, without the group ones.
 }
 
 type Router struct {
@@ -23,15 +28,79 @@ type Router struct {
 	middlewares []MiddlewareFunc
 	Logger      *Logger
 	Groups      []*Group
+
+	// ErrorHandler is called with the error returned by a handler.
+	// It defaults to DefaultErrorHandler.
+	ErrorHandler ErrorHandlerFunc
+
+	// NotFound handles the requests whose path matches no route.
+	// It defaults to returning a 404 Not Found *HTTPError, rendered by the ErrorHandler.
+	NotFound HandlerFunc
+
+	// MethodNotAllowed handles the requests whose path matches a route registered
+	// for other methods. The Allow header is set before it is called.
+	// It defaults to returning a 405 Method Not Allowed *HTTPError, rendered by the ErrorHandler.
+	MethodNotAllowed HandlerFunc
+
+	// CachePolicies set the Cache-Control header of the files served by Static and StaticFS.
+	// The first matching policy applies. No Cache-Control header is set if none matches.
+	CachePolicies []CachePolicy
+
+	// HandleHEAD makes the router answer HEAD requests with the GET route of the path,
+	// discarding the response body, when no HEAD route is registered. It defaults to true.
+	HandleHEAD bool
+
+	// HandleOPTIONS makes the router answer OPTIONS requests with a 204 No Content response
+	// carrying the Allow header, when no OPTIONS route is registered. It defaults to true.
+	// The response goes through the global middlewares, e.g. to answer CORS preflight requests.
+	HandleOPTIONS bool
+
+	// TrustedProxies are the networks of the proxies whose X-Forwarded-For and X-Real-IP
+	// headers are trusted by Context.RealIP, e.g. netip.MustParsePrefix("10.0.0.0/8").
+	// The headers are ignored if it is empty.
+	TrustedProxies []netip.Prefix
+
+	validators map[string]ValidatorFunc
+	names      map[string]*Route
+
+	// notFoundChain, methodNotAllowedChain and optionsChain are the handlers of the requests
+	// without a route, wrapped by the global middlewares.
+	notFoundChain         HandlerFunc
+	methodNotAllowedChain HandlerFunc
+	optionsChain          HandlerFunc
 }
 
 func New() *Router {
-	return &Router{
-		routes:      newRTree(),
-		store:       NewStore(),
-		Logger:      NewLogger(os.Stdout),
-		middlewares: make([]MiddlewareFunc, 0),
+	r := &Router{
+		routes:       newRTree(),
+		store:        NewStore(),
+		Logger:       NewLogger(os.Stdout),
+		middlewares:  make([]MiddlewareFunc, 0),
+		ErrorHandler: DefaultErrorHandler,
+
+		NotFound:         notFound,
+		MethodNotAllowed: methodNotAllowed,
+		HandleHEAD:       true,
+		HandleOPTIONS:    true,
 	}
+	r.compileFallbacks()
+	return r
+}
+
+// notFound is the default NotFound handler of the Router.
+func notFound(*Context) error {
+	return NewHTTPError(http.StatusNotFound)
+}
+
+// methodNotAllowed is the default MethodNotAllowed handler of the Router.
+func methodNotAllowed(*Context) error {
+	return NewHTTPError(http.StatusMethodNotAllowed)
+}
+
+// options is the handler of the OPTIONS requests answered by the Router.
+func options(ctx *Context) error {
+	ctx.Writer.WriteHeader(http.StatusNoContent)
+	return nil
 }
 
 // AddRoute adds a new route to the router.
@@ -39,12 +108,25 @@ func New() *Router {
 // The method parameter specifies the HTTP method (e.g., GET, POST, PUT, DELETE).
 // The pattern parameter specifies the URL pattern that the route should match.
 // The handler parameter is the function that will be called to handle the request.
-func (r *Router) AddRoute(method, pattern string, handler HandlerFunc) {
-	r.routes.add(&Route{
-		Method:  method,
-		Pattern: pattern,
-		Handler: handler,
-	})
+// The pattern may contain dynamic segments (":name") and a trailing catch-all segment ("*name").
+// The optional middlewares only apply to this route and run after the router's global middlewares.
+// It returns the registered route, which can be named with Route.Named.
+// AddRoute panics if the pattern is invalid.
+func (r *Router) AddRoute(method, pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	route := &Route{
+		Method:      method,
+		Pattern:     pattern,
+		Handler:     handler,
+		Middlewares: middlewares,
+		router:      r,
+	}
+	r.compile(route)
+
+	if err := r.routes.add(route); err != nil {
+		panic(err)
+	}
+
+	return route
 }
 
 // Group creates a new group.
@@ -59,123 +141,183 @@ func (r *Router) Group(prefix string) *Group {
 	return g
 }
 
-func (r *Router) GET(pattern string, handler HandlerFunc) {
-	r.AddRoute(http.MethodGet, pattern, handler)
+func (r *Router) GET(pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.AddRoute(http.MethodGet, pattern, handler, middlewares...)
+}
+
+func (r *Router) POST(pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.AddRoute(http.MethodPost, pattern, handler, middlewares...)
 }
 
-func (r *Router) POST(pattern string, handler HandlerFunc) {
-	r.AddRoute(http.MethodPost, pattern, handler)
+func (r *Router) PUT(pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.AddRoute(http.MethodPut, pattern, handler, middlewares...)
 }
 
-func (r *Router) PUT(pattern string, handler HandlerFunc) {
-	r.AddRoute(http.MethodPut, pattern, handler)
+func (r *Router) DELETE(pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.AddRoute(http.MethodDelete, pattern, handler, middlewares...)
 }
 
-func (r *Router) DELETE(pattern string, handler HandlerFunc) {
-	r.AddRoute(http.MethodDelete, pattern, handler)
+func (r *Router) PATCH(pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.AddRoute(http.MethodPatch, pattern, handler, middlewares...)
 }
 
-func (r *Router) PATCH(pattern string, handler HandlerFunc) {
-	r.AddRoute(http.MethodPatch, pattern, handler)
+// HEAD registers a HEAD route, overriding the automatic handling of HEAD requests for the pattern.
+func (r *Router) HEAD(pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.AddRoute(http.MethodHead, pattern, handler, middlewares...)
+}
+
+// OPTIONS registers an OPTIONS route, overriding the automatic handling of OPTIONS requests for the pattern.
+func (r *Router) OPTIONS(pattern string, handler HandlerFunc, middlewares ...MiddlewareFunc) *Route {
+	return r.AddRoute(http.MethodOptions, pattern, handler, middlewares...)
 }
 
 // Use adds a new middleware to the router.
 // It takes the middleware function as parameter.
 // The middleware function is called before the handler function.
+// Middleware chains are composed once per route, so Use recomposes the chains
+// of the routes already registered and must not be called while serving requests.
 func (r *Router) Use(middlewares ...MiddlewareFunc) {
 	r.middlewares = append(r.middlewares, middlewares...)
+	r.routes.each(r.compile)
+	r.compileFallbacks()
 }
 
-// Find returns the route that matches the given path.
-// If no route is found, it returns nil.
-func (r *Router) Find(path string) *Route {
-	return r.routes.find(path)
-}
+// compile composes the middleware chain of the route.
+// The global middlewares wrap the route middlewares, which wrap the handler.
+func (r *Router) compile(route *Route) {
+	handler := route.Handler
 
-// ServeHTTP handles the HTTP requests by finding the appropriate route based on the request URL path,
-// extracting the parameters, and invoking the corresponding handler.
-// If no route is found, it returns a 404 Not Found response.
-func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	route := r.Find(req.URL.Path)
-	if route == nil {
-		http.NotFound(w, req)
-		return
+	for i := len(route.Middlewares) - 1; i >= 0; i-- {
+		handler = route.Middlewares[i](handler)
 	}
 
-	if route.Method != req.Method {
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-		return
-	}
+	route.chain = r.wrap(handler)
+}
 
-	ctx := &Context{
-		Params:  extractParams(req.URL.Path, route.Pattern),
-		Writer:  w,
-		request: req,
-		Store:   r.store,
-		Logger:  r.Logger,
+// trusted reports whether the address belongs to one of the TrustedProxies.
+func (r *Router) trusted(addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
 	}
+	ip = ip.Unmap()
+	for _, prefix := range r.TrustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
 
-	handler := route.Handler
+// compileFallbacks composes the middleware chains of the requests without a route.
+// The chains call the NotFound and MethodNotAllowed handlers set when the request is served,
+// so that the fields can be changed without recomposing them.
+func (r *Router) compileFallbacks() {
+	r.notFoundChain = r.wrap(func(ctx *Context) error {
+		return fallback(r.NotFound, notFound)(ctx)
+	})
+	r.methodNotAllowedChain = r.wrap(func(ctx *Context) error {
+		return fallback(r.MethodNotAllowed, methodNotAllowed)(ctx)
+	})
+	r.optionsChain = r.wrap(options)
+}
 
+// wrap wraps the handler with the global middlewares.
+func (r *Router) wrap(handler HandlerFunc) HandlerFunc {
 	for i := len(r.middlewares) - 1; i >= 0; i-- {
 		handler = r.middlewares[i](handler)
 	}
-
-	handler(ctx)
+	return handler
 }
 
-// Static serves static files from a given directory.
-// The prefix is the first segment of the path.
-// i.e. "/assets/foo.jpg"
-func (r *Router) Static(prefix, dir string) error {
-	if _, err := os.Stat(dir); err != nil {
-		return err
+// Find returns the route that matches the given path.
+// When several methods are registered for the path, the GET route is preferred,
+// otherwise the route with the alphabetically first method is returned.
+// If no route is found, it returns nil. Use Match to find the route of a given method.
+func (r *Router) Find(path string) *Route {
+	node, fallback := r.routes.find(path, http.MethodGet, false)
+	if node != nil {
+		return node.route(http.MethodGet)
 	}
+	if fallback != nil {
+		return fallback.route(fallback.methods()[0])
+	}
+	return nil
+}
 
-	readDir(dir, func(path string, entry os.DirEntry) {
-		content, err := os.ReadFile(path)
-		if err != nil {
-			log.Printf("Error reading file %s: %v", path, err)
-			return
-		}
+// ServeHTTP handles the HTTP requests by finding the appropriate route based on the request URL path,
+// extracting the parameters, and invoking the corresponding handler.
+// If no route is found, the NotFound handler is called.
+// HEAD requests without a HEAD route are handled by the GET route if HandleHEAD is set,
+// and OPTIONS requests without an OPTIONS route are answered with the Allow header
+// if HandleOPTIONS is set.
+// If the path matches but no route is registered for the request method, the Allow header
+// is set to the list of the registered methods and the MethodNotAllowed handler is called.
+// The NotFound, MethodNotAllowed and automatic OPTIONS handlers run through the global
+// middlewares, with a nil Context.Route.
+// If the handler returns an error, it is passed to the ErrorHandler.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx := &Context{
+		Writer:  NewResponseWriter(w),
+		request: req,
+		Store:   r.store,
+		Logger:  r.Logger,
+		router:  r,
+	}
 
-		path = resolvePath(path)
-		if path == "index.html" || path == "index.htm" {
-			path = ""
+	var handler HandlerFunc
+	node, fallback := r.routes.find(req.URL.Path, req.Method, r.HandleHEAD)
+	switch {
+	case node != nil:
+		route := node.route(req.Method)
+		if route == nil {
+			// The GET route answers the HEAD request.
+			route = node.route(http.MethodGet)
+			ctx.Writer.discard = true
 		}
+		ctx.route = route
+		ctx.Params = extractParams(req.URL.Path, route.Pattern)
+		handler = route.chain
+	case fallback == nil:
+		handler = r.notFoundChain
+	case req.Method == http.MethodOptions && r.HandleOPTIONS:
+		w.Header().Set("Allow", fallback.allow(r.HandleHEAD, r.HandleOPTIONS))
+		handler = r.optionsChain
+	default:
+		w.Header().Set("Allow", fallback.allow(r.HandleHEAD, r.HandleOPTIONS))
+		handler = r.methodNotAllowedChain
+	}
 
-		r.registerStaticRoute(prefix+"/"+path, content)
-
-	})
-
-	return nil
+	if err := handler(ctx); err != nil {
+		r.handleError(ctx, err)
+	}
 }
 
-func (r *Router) registerStaticRoute(pattern string, content []byte) error {
-	r.GET(pattern, func(ctx *Context) error {
-		setContentType(filepath.Ext(pattern), ctx)
-		return ctx.Send(200, content)
-	})
-	return nil
+// fallback returns the handler, or def if the handler is nil.
+func fallback(handler, def HandlerFunc) HandlerFunc {
+	if handler != nil {
+		return handler
+	}
+	return def
 }
 
-func resolvePath(path string) string {
-	segments := strings.Split(path, string(os.PathSeparator))
-	if len(segments) > 1 {
-		segments = segments[1:]
+// handleError passes the error to the router's ErrorHandler,
+// falling back to DefaultErrorHandler if none is set.
+func (r *Router) handleError(ctx *Context, err error) {
+	if r.ErrorHandler != nil {
+		r.ErrorHandler(ctx, err)
+		return
 	}
-	return strings.Join(segments, "/")
+	DefaultErrorHandler(ctx, err)
 }
 
-func setContentType(ext string, ctx *Context) {
-	switch ext {
-	case ".html", ".htm":
-		ctx.SetHeader("Content-Type", "text/html")
-	case ".css":
-		ctx.SetHeader("Content-Type", "text/css")
-	case ".js":
-		ctx.SetHeader("Content-Type", "text/javascript")
-	case ".png", ".jpg", ".jpeg", ".gif":
-		ctx.SetHeader("content-type", "image/"+strings.TrimPrefix(ext, "."))
+// Static serves the files of the directory dir under the given prefix,
+// e.g. "/assets/css/site.css" for the file "css/site.css" with the "/assets" prefix.
+// Files are streamed from disk on demand, as described by StaticFS.
+// It returns an error if dir cannot be read.
+func (r *Router) Static(prefix, dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
 	}
+	return r.StaticFS(prefix, os.DirFS(dir))
 }
@@ -0,0 +1,93 @@
+package gort
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// routeInfo is the description of a route rendered by PrintRoutes and RoutesHandler.
+type routeInfo struct {
+	Method      string `json:"method"`
+	Pattern     string `json:"pattern"`
+	Name        string `json:"name,omitempty"`
+	Middlewares int    `json:"middlewares"`
+}
+
+// Routes returns the registered routes, sorted by pattern and method.
+// The returned values are copies: modifying them does not affect the router.
+func (r *Router) Routes() []Route {
+	var routes []Route
+	r.routes.each(func(route *Route) {
+		routes = append(routes, *route)
+	})
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	return routes
+}
+
+// Match returns the route registered for the given method and path,
+// along with the parameters extracted from the path.
+// HEAD requests match GET routes when HandleHEAD is set, as in ServeHTTP.
+// If no route matches, it returns nil and nil.
+func (r *Router) Match(method, path string) (*Route, map[string]string) {
+	node, _ := r.routes.find(path, method, r.HandleHEAD)
+	if node == nil {
+		return nil, nil
+	}
+
+	route := node.route(method)
+	if route == nil {
+		route = node.route(http.MethodGet)
+	}
+
+	return route, extractParams(path, route.Pattern)
+}
+
+// routeInfos returns the description of the registered routes.
+// The middleware count includes the global middlewares.
+func (r *Router) routeInfos() []routeInfo {
+	routes := r.Routes()
+	infos := make([]routeInfo, len(routes))
+	for i, route := range routes {
+		infos[i] = routeInfo{
+			Method:      route.Method,
+			Pattern:     route.Pattern,
+			Name:        route.Name,
+			Middlewares: len(r.middlewares) + len(route.Middlewares),
+		}
+	}
+	return infos
+}
+
+// PrintRoutes writes the route table to w, one route per line, e.g. for startup logs:
+//
+//	METHOD  PATTERN      NAME   MIDDLEWARES
+//	GET     /users/:id   user   2
+func (r *Router) PrintRoutes(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATTERN\tNAME\tMIDDLEWARES")
+	for _, info := range r.routeInfos() {
+		fmt.Fprintln(tw, info.Method+"\t"+info.Pattern+"\t"+dash(info.Name)+"\t"+strconv.Itoa(info.Middlewares))
+	}
+	return tw.Flush()
+}
+
+// RoutesHandler returns a handler responding with the route table as a JSON array,
+// to be registered on a debug endpoint:
+//
+//	router.GET("/debug/routes", router.RoutesHandler())
+func (r *Router) RoutesHandler() HandlerFunc {
+	return func(ctx *Context) error {
+		return ctx.JSON(http.StatusOK, r.routeInfos())
+	}
+}
@@ -1,7 +1,13 @@
 package gort
 
 import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 type rtree struct {
@@ -9,11 +15,15 @@ type rtree struct {
 }
 
 type rnode struct {
-	children     map[string]*rnode // children is a map that stores the child nodes of the current node.
-	dynamicChild *rnode            // dynamicChild is a pointer to the dynamic child node of the current node.
-	route        *Route            // route is a pointer to the Route associated with the current node.
-	isLast       bool              // isLast indicates whether the current node is the last node in a route.
-	isDynamic    bool              // isDynamic indicates whether the current node is a dynamic node.
+	part            string            // part is the pattern segment the current node was created for.
+	children        map[string]*rnode // children is a map that stores the child nodes of the current node.
+	dynamicChildren []*rnode          // dynamicChildren are the dynamic child nodes of the current node, constrained ones first.
+	wildcardChild   *rnode            // wildcardChild is a pointer to the catch-all child node of the current node.
+	constraint      paramConstraint   // constraint restricts the segments matched by a dynamic node, if not nil.
+	routes          map[string]*Route // routes maps an HTTP method to the Route registered for it on the current node.
+	isLast          bool              // isLast indicates whether the current node is the last node in a route.
+	isDynamic       bool              // isDynamic indicates whether the current node is a dynamic node.
+	isWildcard      bool              // isWildcard indicates whether the current node is a catch-all node.
 }
 
 func newRTree() *rtree {
@@ -30,65 +40,306 @@ func newRTree() *rtree {
 // Each part is then used to traverse the rtree and create or update the corresponding nodes.
 // If a part is empty, it is skipped.
 // If a node for a part does not exist, a new node is created and added to the current node's children.
-// If the part is a dynamic part (starts with ":"), the current node's dynamicChild is updated.
-// Finally, the last node in the traversal is marked as the last node and its route is set to the input route.
-func (t *rtree) add(r *Route) {
+// If the part is a dynamic part (starts with ":"), it is added to the current node's dynamicChildren.
+// A dynamic part may be followed by a constraint between angle brackets, either a named constraint
+// (e.g. ":id<int>") or a regular expression matching the whole segment (e.g. ":slug<[a-z-]+>").
+// The named constraints are int, uint, float, bool, alpha and uuid. Expressions cannot contain "/":
+// an error is returned if a dynamic part contains "<" without ending with ">".
+// If the part is a catch-all part (starts with "*"), the current node's wildcardChild is updated.
+// A catch-all part must be the last part of the pattern, otherwise an error is returned.
+// An error is also returned if a constraint is invalid, or if a dynamic or catch-all part conflicts
+// with a differently named one with the same constraint already registered at the same position
+// (e.g. "/users/:id" and "/users/:name").
+// Finally, the last node in the traversal is marked as the last node and the input route is stored
+// under its method, replacing any route previously registered for the same method and pattern.
+func (t *rtree) add(r *Route) error {
 	current := t.root
 	parts := split(r.Pattern)
 
-	for _, part := range parts {
+	for i, part := range parts {
 		if part == "" {
 			continue
 		}
 
+		isWildcard := strings.HasPrefix(part, "*")
+		if isWildcard && strings.Join(parts[i+1:], "") != "" {
+			return fmt.Errorf("gort: catch-all segment %q must be the last segment in pattern %q", part, r.Pattern)
+		}
+
+		if strings.HasPrefix(part, ":") {
+			if name, _ := parseParam(part); strings.ContainsAny(name, "<>") {
+				return fmt.Errorf("gort: unterminated constraint in segment %q of pattern %q, constraints cannot contain \"/\"", part, r.Pattern)
+			}
+		}
+
 		if _, ok := current.children[part]; !ok {
+			if err := current.conflict(part, r.Pattern); err != nil {
+				return err
+			}
+
 			newNode := &rnode{
-				children:  make(map[string]*rnode),
-				isDynamic: strings.HasPrefix(part, ":"),
+				part:       part,
+				children:   make(map[string]*rnode),
+				isDynamic:  strings.HasPrefix(part, ":"),
+				isWildcard: isWildcard,
 			}
-			current.children[part] = newNode
 			if newNode.isDynamic {
-				current.dynamicChild = newNode
+				_, expr := parseParam(part)
+				constraint, err := newParamConstraint(expr)
+				if err != nil {
+					return fmt.Errorf("gort: invalid constraint in segment %q of pattern %q: %v", part, r.Pattern, err)
+				}
+				newNode.constraint = constraint
+				current.addDynamicChild(newNode)
 			}
+			if newNode.isWildcard {
+				current.wildcardChild = newNode
+			}
+			current.children[part] = newNode
 		}
 
 		current = current.children[part]
 	}
 
+	if current.routes == nil {
+		current.routes = make(map[string]*Route)
+	}
+
 	current.isLast = true
-	current.route = r
+	current.routes[r.Method] = r
+
+	return nil
 }
 
-// find searches for a route in the rtree based on the given path.
-// It returns the corresponding Route if found, otherwise it returns nil.
-func (t *rtree) find(path string) *Route {
-	current := t.root
-	parts := split(path)
+// find searches for the node matching the given path with a route for the given method.
+// If head is true, a GET route also matches HEAD requests.
+// It returns the matching node, or nil, and the first node matching the path regardless
+// of the method, or nil, which provides the Allow header when the method is not allowed.
+// The caller is responsible for selecting the Route for the request method.
+//
+// Static children take precedence over dynamic children, which take precedence over
+// catch-all children. A catch-all child matches the remainder of the path, including
+// an empty remainder. If a branch does not lead to a route for the method, the search
+// backtracks and tries the next branch in order of precedence.
+func (t *rtree) find(path, method string, head bool) (node, fallback *rnode) {
+	node = t.root.match(path, method, head, &fallback)
+	return node, fallback
+}
 
-	for _, part := range parts {
-		if part == "" {
+// match returns the node matching the given path relative to the current node
+// with a route for the method, or nil. Empty segments in the path are skipped.
+// The first node matching the path regardless of the method is stored in fallback.
+func (n *rnode) match(path, method string, head bool, fallback **rnode) *rnode {
+	part, rest := nextSegment(path)
+	if part == "" {
+		if found := n.accept(method, head, fallback); found != nil {
+			return found
+		}
+		if w := n.wildcardChild; w != nil {
+			return w.accept(method, head, fallback)
+		}
+		return nil
+	}
+
+	if child, ok := n.children[part]; ok && !child.isDynamic && !child.isWildcard {
+		if found := child.match(rest, method, head, fallback); found != nil {
+			return found
+		}
+	}
+
+	for _, child := range n.dynamicChildren {
+		if !child.constraint.match(part) {
 			continue
 		}
+		if found := child.match(rest, method, head, fallback); found != nil {
+			return found
+		}
+	}
+
+	if w := n.wildcardChild; w != nil {
+		return w.accept(method, head, fallback)
+	}
+
+	return nil
+}
+
+// accept returns the node if it ends a route registered for the method, or nil.
+// If head is true, a GET route is accepted for the HEAD method.
+// A node ending routes for other methods is stored in fallback if it is the first one.
+func (n *rnode) accept(method string, head bool, fallback **rnode) *rnode {
+	if !n.isLast {
+		return nil
+	}
+	if n.routes[method] != nil || (head && method == http.MethodHead && n.routes[http.MethodGet] != nil) {
+		return n
+	}
+	if *fallback == nil {
+		*fallback = n
+	}
+	return nil
+}
+
+// nextSegment splits the path into its first segment and the rest of the path.
+// Leading slashes are skipped, so that empty segments are ignored.
+func nextSegment(path string) (part, rest string) {
+	path = strings.TrimLeft(path, "/")
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return path[:i], path[i:]
+	}
+	return path, ""
+}
 
-		next, ok := current.children[part]
-		if !ok {
-			if current.dynamicChild != nil {
-				next = current.dynamicChild
-			} else {
-				return nil
+// conflict returns an error if a dynamic or catch-all part cannot be added as a child of the node
+// because a differently named child with the same constraint already exists at the same position.
+func (n *rnode) conflict(part, pattern string) error {
+	var existing *rnode
+	switch {
+	case strings.HasPrefix(part, ":"):
+		name, expr := parseParam(part)
+		for _, child := range n.dynamicChildren {
+			childName, childExpr := parseParam(child.part)
+			if childExpr == expr && childName != name {
+				existing = child
+				break
 			}
 		}
+	case strings.HasPrefix(part, "*"):
+		existing = n.wildcardChild
+	}
 
-		current = next
+	if existing != nil && existing.part != part {
+		return fmt.Errorf("gort: segment %q in pattern %q conflicts with existing segment %q", part, pattern, existing.part)
 	}
 
-	if !current.isLast {
-		return nil
+	return nil
+}
+
+// addDynamicChild adds a dynamic child to the node.
+// Constrained children are kept before the unconstrained one, so they are tried first.
+func (n *rnode) addDynamicChild(child *rnode) {
+	if child.constraint == nil {
+		n.dynamicChildren = append(n.dynamicChildren, child)
+		return
+	}
+
+	i := len(n.dynamicChildren)
+	if i > 0 && n.dynamicChildren[i-1].constraint == nil {
+		i--
+	}
+	n.dynamicChildren = append(n.dynamicChildren, nil)
+	copy(n.dynamicChildren[i+1:], n.dynamicChildren[i:])
+	n.dynamicChildren[i] = child
+}
+
+// each calls fn for every Route stored in the rtree.
+func (t *rtree) each(fn func(*Route)) {
+	t.root.each(fn)
+}
+
+// each calls fn for every Route stored in the current node and its descendants.
+func (n *rnode) each(fn func(*Route)) {
+	for _, route := range n.routes {
+		fn(route)
+	}
+
+	for _, child := range n.children {
+		child.each(fn)
+	}
+}
+
+// route returns the Route registered for the given method, or nil if there is none.
+func (n *rnode) route(method string) *Route {
+	return n.routes[method]
+}
+
+// methods returns the sorted list of methods registered on the node.
+func (n *rnode) methods() []string {
+	methods := make([]string, 0, len(n.routes))
+	for method := range n.routes {
+		methods = append(methods, method)
 	}
+	sort.Strings(methods)
+	return methods
+}
 
-	return current.route
+// allow returns the value of the Allow header for the node.
+// If head is true, HEAD is included when a GET route is registered.
+// If options is true, OPTIONS is always included.
+func (n *rnode) allow(head, options bool) string {
+	methods := n.methods()
+	if head && n.route(http.MethodGet) != nil && n.route(http.MethodHead) == nil {
+		methods = append(methods, http.MethodHead)
+	}
+	if options && n.route(http.MethodOptions) == nil {
+		methods = append(methods, http.MethodOptions)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
 }
 
 func split(p string) []string {
 	return strings.Split(p, "/")
 }
+
+// parseParam returns the name and the constraint expression of a dynamic part,
+// e.g. "id" and "int" for ":id<int>".
+func parseParam(part string) (name, expr string) {
+	name = strings.TrimPrefix(part, ":")
+	if i := strings.IndexByte(name, '<'); i >= 0 && strings.HasSuffix(name, ">") {
+		return name[:i], name[i+1 : len(name)-1]
+	}
+	return name, ""
+}
+
+// paramConstraint restricts the values matched by a dynamic segment.
+type paramConstraint func(string) bool
+
+// match reports whether the segment satisfies the constraint. A nil constraint matches any segment.
+func (c paramConstraint) match(segment string) bool {
+	return c == nil || c(segment)
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// namedConstraints are the constraints usable by name in patterns, e.g. ":id<int>".
+var namedConstraints = map[string]paramConstraint{
+	"int": func(s string) bool {
+		_, err := strconv.ParseInt(s, 10, 64)
+		return err == nil
+	},
+	"uint": func(s string) bool {
+		_, err := strconv.ParseUint(s, 10, 64)
+		return err == nil
+	},
+	"float": func(s string) bool {
+		_, err := strconv.ParseFloat(s, 64)
+		return err == nil
+	},
+	"bool": func(s string) bool {
+		_, err := strconv.ParseBool(s)
+		return err == nil
+	},
+	"alpha": func(s string) bool {
+		return s != "" && strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) }) < 0
+	},
+	"uuid": uuidPattern.MatchString,
+}
+
+// newParamConstraint returns the constraint for the given expression,
+// either a named constraint or a regular expression matching the whole segment.
+// It returns nil if the expression is empty.
+func newParamConstraint(expr string) (paramConstraint, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	if constraint, ok := namedConstraints[expr]; ok {
+		return constraint, nil
+	}
+
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString, nil
+}
@@ -0,0 +1,185 @@
+package gort
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// SlogLevel returns the slog.Level corresponding to the level.
+func (l Level) SlogLevel() slog.Level {
+	switch l {
+	case DEBUG:
+		return slog.LevelDebug
+	case WARNING:
+		return slog.LevelWarn
+	case ERROR:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// levelFromSlog returns the Level corresponding to the slog.Level.
+// Levels between the slog levels are rounded down, e.g. slog.LevelInfo+2 is INFO.
+func levelFromSlog(level slog.Level) Level {
+	switch {
+	case level < slog.LevelInfo:
+		return DEBUG
+	case level < slog.LevelWarn:
+		return INFO
+	case level < slog.LevelError:
+		return WARNING
+	default:
+		return ERROR
+	}
+}
+
+// SlogEncoder is a LogEncoder passing events to a slog.Handler instead of writing them.
+// Fields are passed as attributes.
+type SlogEncoder struct {
+	Handler slog.Handler
+}
+
+func (e SlogEncoder) Encode(_ io.Writer, event Event) error {
+	ctx := context.Background()
+	level := event.Level.SlogLevel()
+	if !e.Handler.Enabled(ctx, level) {
+		return nil
+	}
+
+	record := slog.NewRecord(event.Timestamp, level, event.Message, 0)
+	for _, f := range event.Fields {
+		record.AddAttrs(slog.Any(f.Key, f.Value))
+	}
+
+	return e.Handler.Handle(ctx, record)
+}
+
+// NewSlogLogger creates a Logger backed by the given slog.Handler.
+// It can be used as Router.Logger to send the router's logs to log/slog.
+func NewSlogLogger(h slog.Handler) *Logger {
+	logger := NewLogger(io.Discard)
+	logger.SetEncoder(SlogEncoder{Handler: h})
+	return logger
+}
+
+// SlogHandler is a slog.Handler writing records through a Logger.
+// Record attributes become event fields, with the names of nested groups
+// joined to the attribute key with a dot.
+// Attributes added to the context with WithLogAttrs are included in every record.
+type SlogHandler struct {
+	logger *Logger
+	prefix string
+	base   context.Context // base provides the attributes when the record's context has none.
+}
+
+// NewSlogHandler creates a slog.Handler writing through the given Logger.
+func NewSlogHandler(l *Logger) *SlogHandler {
+	return &SlogHandler{logger: l}
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.Enabled(levelFromSlog(level))
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	fields := make([]Field, 0, record.NumAttrs())
+	attrs, ok := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	if !ok && h.base != nil {
+		attrs, _ = h.base.Value(logAttrsKey{}).([]slog.Attr)
+	}
+	for _, attr := range attrs {
+		fields = appendAttr(fields, "", attr)
+	}
+
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, attr)
+		return true
+	})
+
+	timestamp := record.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	h.logger.log(timestamp, levelFromSlog(record.Level), record.Message, fields)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]Field, 0, len(attrs))
+	for _, attr := range attrs {
+		fields = appendAttr(fields, h.prefix, attr)
+	}
+
+	args := make([]any, len(fields))
+	for i, f := range fields {
+		args[i] = f
+	}
+
+	return &SlogHandler{
+		logger: h.logger.With(args...),
+		prefix: h.prefix,
+		base:   h.base,
+	}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &SlogHandler{
+		logger: h.logger,
+		prefix: h.prefix + name + ".",
+		base:   h.base,
+	}
+}
+
+// appendAttr appends the attribute to fields, flattening groups.
+func appendAttr(fields []Field, prefix string, attr slog.Attr) []Field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, a := range attr.Value.Group() {
+			fields = appendAttr(fields, prefix, a)
+		}
+		return fields
+	}
+
+	return append(fields, Field{Key: prefix + attr.Key, Value: attr.Value.Any()})
+}
+
+type logAttrsKey struct{}
+
+// WithLogAttrs returns a copy of ctx carrying the given attributes.
+// SlogHandler adds them to every record logged with the returned context.
+func WithLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	all := make([]slog.Attr, 0, len(existing)+len(attrs))
+	all = append(all, existing...)
+	all = append(all, attrs...)
+	return context.WithValue(ctx, logAttrsKey{}, all)
+}
+
+// Slog returns a slog.Logger writing through the context's Logger.
+// Records carry the request method and path, and the attributes added
+// to the request context with WithLogAttrs.
+func (ctx *Context) Slog() *slog.Logger {
+	h := &SlogHandler{
+		logger: ctx.Logger,
+		base:   ctx.request.Context(),
+	}
+	return slog.New(h).With(
+		slog.String("method", ctx.request.Method),
+		slog.String("path", ctx.request.URL.Path),
+	)
+}
@@ -0,0 +1,87 @@
+package gort
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Named names the route so that its URL can be built with Router.URL.
+// Naming another route with the same name replaces it.
+func (route *Route) Named(name string) *Route {
+	route.Name = name
+
+	r := route.router
+	if r == nil {
+		return route
+	}
+	if r.names == nil {
+		r.names = make(map[string]*Route)
+	}
+	r.names[name] = route
+
+	return route
+}
+
+// URL builds the path of the route with the given name, filling its dynamic
+// and catch-all segments from params, given as alternating names and values:
+//
+//	router.URL("user-post", "id", "42", "post", "hello-world")
+//
+// Values are escaped, except for the slashes of catch-all values.
+// URL returns an error if no route has the name, if a parameter is missing,
+// or if a value does not satisfy the constraint of its segment.
+func (r *Router) URL(name string, params ...string) (string, error) {
+	route, ok := r.names[name]
+	if !ok {
+		return "", fmt.Errorf("gort: no route named %q", name)
+	}
+
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("gort: odd number of parameters for route %q", name)
+	}
+
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+
+	parts := split(route.Pattern)
+	for i, part := range parts {
+		switch {
+		case strings.HasPrefix(part, ":"):
+			param, expr := parseParam(part)
+			value, ok := values[param]
+			if !ok {
+				return "", fmt.Errorf("gort: missing parameter %q for route %q", param, name)
+			}
+
+			constraint, err := newParamConstraint(expr)
+			if err != nil {
+				return "", err
+			}
+			if !constraint.match(value) {
+				return "", fmt.Errorf("gort: parameter %q of route %q does not match <%s>: %q", param, name, expr, value)
+			}
+
+			parts[i] = url.PathEscape(value)
+		case strings.HasPrefix(part, "*"):
+			param := part[1:]
+			if param == "" {
+				param = "*"
+			}
+			value, ok := values[param]
+			if !ok {
+				return "", fmt.Errorf("gort: missing parameter %q for route %q", param, name)
+			}
+
+			segments := strings.Split(value, "/")
+			for j, segment := range segments {
+				segments[j] = url.PathEscape(segment)
+			}
+			parts[i] = strings.Join(segments, "/")
+		}
+	}
+
+	return strings.Join(parts, "/"), nil
+}
@@ -0,0 +1,331 @@
+package gort
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ValidatorFunc reports whether the field value satisfies a validation rule.
+// The param is the text after "=" in the rule, e.g. "64" for "max=64".
+type ValidatorFunc func(field reflect.Value, param string) bool
+
+// FieldError describes a field failing a validation rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// ValidationErrors is the list of the fields failing validation.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// validators are the built-in validation rules.
+var validators = map[string]ValidatorFunc{
+	"required": validateRequired,
+	"min":      validateMin,
+	"max":      validateMax,
+	"len":      validateLen,
+	"oneof":    validateOneOf,
+	"email":    validateEmail,
+	"url":      validateURL,
+	"alpha":    validateString(unicode.IsLetter),
+	"alphanum": validateString(func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }),
+	"numeric":  validateString(unicode.IsDigit),
+}
+
+// RegisterValidator registers a custom validation rule usable in `validate` tags.
+// It replaces the built-in rule with the same name, if any.
+func (r *Router) RegisterValidator(name string, fn ValidatorFunc) {
+	if r.validators == nil {
+		r.validators = make(map[string]ValidatorFunc)
+	}
+	r.validators[name] = fn
+}
+
+// Validate checks the struct pointed to by v against the rules of its `validate` tags,
+// e.g. `validate:"required,min=1,max=64,email"`.
+//
+// Rules are separated by commas. The built-in rules are required, min, max, len, oneof
+// (space separated values), email, url, alpha, alphanum and numeric; min, max and len
+// compare the length of strings, slices and maps, and the value of numbers.
+// The omitempty rule skips the other rules when the field is empty.
+// Custom rules are registered with Router.RegisterValidator.
+// Nested and embedded structs are validated too.
+//
+// Validate returns a 422 Unprocessable Entity *HTTPError wrapping the ValidationErrors
+// listing every failing field.
+func (ctx *Context) Validate(v any) error {
+	var custom map[string]ValidatorFunc
+	if ctx.router != nil {
+		custom = ctx.router.validators
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("gort: Validate argument must be a struct or a pointer to a struct, got %T", v)
+	}
+
+	var errs ValidationErrors
+	if err := validateStruct(rv, "", custom, &errs); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return NewHTTPError(http.StatusUnprocessableEntity).Wrap(errs)
+	}
+	return nil
+}
+
+// validateStruct validates the fields of the struct v, appending the failures to errs.
+// The prefix is prepended to the field names of nested structs.
+func validateStruct(v reflect.Value, prefix string, custom map[string]ValidatorFunc, errs *ValidationErrors) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		field := v.Field(i)
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := validateStruct(field, prefix, custom, errs); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := prefix + fieldName(sf)
+
+		if tag := sf.Tag.Get("validate"); tag != "" && tag != "-" {
+			if err := validateField(field, name, tag, custom, errs); err != nil {
+				return err
+			}
+		}
+
+		nested := field
+		if nested.Kind() == reflect.Pointer && !nested.IsNil() {
+			nested = nested.Elem()
+		}
+		if nested.Kind() == reflect.Struct && nested.Type() != timeType {
+			if err := validateStruct(nested, name+".", custom, errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateField checks the field against the rules of the tag, appending the failures to errs.
+// Rules that are not registered return an error.
+func validateField(field reflect.Value, name, tag string, custom map[string]ValidatorFunc, errs *ValidationErrors) error {
+	rules := strings.Split(tag, ",")
+
+	for _, rule := range rules {
+		if rule == "omitempty" && field.IsZero() {
+			return nil
+		}
+	}
+
+	for _, rule := range rules {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if rule == "" || rule == "omitempty" {
+			continue
+		}
+
+		fn, ok := custom[rule]
+		if !ok {
+			fn, ok = validators[rule]
+		}
+		if !ok {
+			return fmt.Errorf("gort: unknown validation rule %q on field %s", rule, name)
+		}
+
+		value := field
+		if rule != "required" {
+			for value.Kind() == reflect.Pointer {
+				if value.IsNil() {
+					break
+				}
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Pointer {
+				continue
+			}
+		}
+
+		if !fn(value, param) {
+			*errs = append(*errs, FieldError{
+				Field:   name,
+				Rule:    rule,
+				Param:   param,
+				Message: ruleMessage(rule, param),
+			})
+			if rule == "required" {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// fieldName returns the name of the field as seen by the client,
+// taken from its binding tags, or the Go field name.
+func fieldName(sf reflect.StructField) string {
+	for _, tag := range []string{"json", "xml", "form", "query", "param", "header"} {
+		name, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+// ruleMessage returns the description of a failed rule.
+func ruleMessage(rule, param string) string {
+	switch rule {
+	case "required":
+		return "is required"
+	case "min":
+		return "must be at least " + param
+	case "max":
+		return "must be at most " + param
+	case "len":
+		return "must have a length of " + param
+	case "oneof":
+		return "must be one of [" + param + "]"
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "alpha":
+		return "must contain only letters"
+	case "alphanum":
+		return "must contain only letters and digits"
+	case "numeric":
+		return "must contain only digits"
+	default:
+		return "failed the " + rule + " validation"
+	}
+}
+
+func validateRequired(field reflect.Value, _ string) bool {
+	return !field.IsZero()
+}
+
+func validateMin(field reflect.Value, param string) bool {
+	cmp, ok := compare(field, param)
+	return ok && cmp >= 0
+}
+
+func validateMax(field reflect.Value, param string) bool {
+	cmp, ok := compare(field, param)
+	return ok && cmp <= 0
+}
+
+func validateLen(field reflect.Value, param string) bool {
+	cmp, ok := compare(field, param)
+	return ok && cmp == 0
+}
+
+// compare compares the size of the field to param: the length of strings, slices, arrays and maps,
+// or the value of numbers. It returns -1, 0 or 1, and false if the field or param is not comparable.
+func compare(field reflect.Value, param string) (int, bool) {
+	var size float64
+	switch field.Kind() {
+	case reflect.String:
+		size = float64(utf8.RuneCountInString(field.String()))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		size = float64(field.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(field.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(field.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = field.Float()
+	default:
+		return 0, false
+	}
+
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	switch {
+	case size < limit:
+		return -1, true
+	case size > limit:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+func validateOneOf(field reflect.Value, param string) bool {
+	value := fmt.Sprint(field.Interface())
+	for _, option := range strings.Fields(param) {
+		if value == option {
+			return true
+		}
+	}
+	return false
+}
+
+func validateEmail(field reflect.Value, _ string) bool {
+	if field.Kind() != reflect.String {
+		return false
+	}
+	addr, err := mail.ParseAddress(field.String())
+	return err == nil && addr.Address == field.String()
+}
+
+func validateURL(field reflect.Value, _ string) bool {
+	if field.Kind() != reflect.String {
+		return false
+	}
+	u, err := url.Parse(field.String())
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// validateString returns a validator checking that a string is not empty
+// and that all its runes satisfy fn.
+func validateString(fn func(rune) bool) ValidatorFunc {
+	return func(field reflect.Value, _ string) bool {
+		if field.Kind() != reflect.String || field.Len() == 0 {
+			return false
+		}
+		for _, r := range field.String() {
+			if !fn(r) {
+				return false
+			}
+		}
+		return true
+	}
+}